@@ -9,7 +9,21 @@ It is generated from these files:
 
 It has these top-level messages:
 	ExecRequest
+	ExecInput
 	ExecReply
+	ExecStatus
+	WindowSize
+	ListCommandsRequest
+	ListCommandsReply
+	CommandInfo
+	LongFlag
+	ValidateExecReply
+	RuleResult
+	CheckResult
+	FlagArg
+	Positional
+	PathRule
+	Target
 */
 package admin
 
@@ -34,10 +48,84 @@ var _ = math.Inf
 // proto package needs to be updated.
 const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
 
+// Which output of the remote process a chunk was read from
+type ExecReply_Stream int32
+
+const (
+	ExecReply_STDOUT ExecReply_Stream = 0
+	ExecReply_STDERR ExecReply_Stream = 1
+)
+
+var ExecReply_Stream_name = map[int32]string{
+	0: "STDOUT",
+	1: "STDERR",
+}
+var ExecReply_Stream_value = map[string]int32{
+	"STDOUT": 0,
+	"STDERR": 1,
+}
+
+func (x ExecReply_Stream) String() string {
+	return proto.EnumName(ExecReply_Stream_name, int32(x))
+}
+func (ExecReply_Stream) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{2, 0} }
+
+// What was checked
+type CheckResult_Kind int32
+
+const (
+	CheckResult_SYNTAX        CheckResult_Kind = 0
+	CheckResult_SHORT_FLAG    CheckResult_Kind = 1
+	CheckResult_LONG_FLAG     CheckResult_Kind = 2
+	CheckResult_NOUN          CheckResult_Kind = 3
+	CheckResult_REQUIRED_FLAG CheckResult_Kind = 4
+	CheckResult_AUTHZ         CheckResult_Kind = 5
+	CheckResult_SUBCOMMAND    CheckResult_Kind = 6
+	CheckResult_TARGET        CheckResult_Kind = 7
+)
+
+var CheckResult_Kind_name = map[int32]string{
+	0: "SYNTAX",
+	1: "SHORT_FLAG",
+	2: "LONG_FLAG",
+	3: "NOUN",
+	4: "REQUIRED_FLAG",
+	5: "AUTHZ",
+	6: "SUBCOMMAND",
+	7: "TARGET",
+}
+var CheckResult_Kind_value = map[string]int32{
+	"SYNTAX":        0,
+	"SHORT_FLAG":    1,
+	"LONG_FLAG":     2,
+	"NOUN":          3,
+	"REQUIRED_FLAG": 4,
+	"AUTHZ":         5,
+	"SUBCOMMAND":    6,
+	"TARGET":        7,
+}
+
+func (x CheckResult_Kind) String() string {
+	return proto.EnumName(CheckResult_Kind_name, int32(x))
+}
+func (CheckResult_Kind) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{11, 0} }
+
 // Request message
 type ExecRequest struct {
 	CmdName string   `protobuf:"bytes,1,opt,name=cmdName" json:"cmdName,omitempty"`
 	CmdArgs []string `protobuf:"bytes,2,rep,name=cmdArgs" json:"cmdArgs,omitempty"`
+	// tty runs the command on a pseudo terminal. Only valid with StreamExec
+	// and commands which permit it.
+	Tty bool `protobuf:"varint,3,opt,name=tty" json:"tty,omitempty"`
+	// windowSize is the initial size of the terminal if tty is set
+	WindowSize *WindowSize `protobuf:"bytes,4,opt,name=windowSize" json:"windowSize,omitempty"`
+	// timeoutSeconds asks for the command to be terminated if it runs longer.
+	// The server may enforce a shorter timeout. 0 means no timeout.
+	TimeoutSeconds uint32 `protobuf:"varint,5,opt,name=timeoutSeconds" json:"timeoutSeconds,omitempty"`
+	// target, if set, runs the command in the namespaces of a container
+	// instead of the host. Only commands which permit it may be run in a
+	// target.
+	Target *Target `protobuf:"bytes,6,opt,name=target" json:"target,omitempty"`
 }
 
 func (m *ExecRequest) Reset()                    { *m = ExecRequest{} }
@@ -59,15 +147,94 @@ func (m *ExecRequest) GetCmdArgs() []string {
 	return nil
 }
 
+func (m *ExecRequest) GetTty() bool {
+	if m != nil {
+		return m.Tty
+	}
+	return false
+}
+
+func (m *ExecRequest) GetWindowSize() *WindowSize {
+	if m != nil {
+		return m.WindowSize
+	}
+	return nil
+}
+
+func (m *ExecRequest) GetTimeoutSeconds() uint32 {
+	if m != nil {
+		return m.TimeoutSeconds
+	}
+	return 0
+}
+
+func (m *ExecRequest) GetTarget() *Target {
+	if m != nil {
+		return m.Target
+	}
+	return nil
+}
+
+// Client messages of a StreamExec
+type ExecInput struct {
+	// request must be set in the first message and only the first message
+	Request *ExecRequest `protobuf:"bytes,1,opt,name=request" json:"request,omitempty"`
+	Stdin   []byte       `protobuf:"bytes,2,opt,name=stdin,proto3" json:"stdin,omitempty"`
+	// eof closes stdin of the remote process after any stdin in this message
+	Eof bool `protobuf:"varint,3,opt,name=eof" json:"eof,omitempty"`
+	// resize changes the size of the terminal of a tty session
+	Resize *WindowSize `protobuf:"bytes,4,opt,name=resize" json:"resize,omitempty"`
+}
+
+func (m *ExecInput) Reset()                    { *m = ExecInput{} }
+func (m *ExecInput) String() string            { return proto.CompactTextString(m) }
+func (*ExecInput) ProtoMessage()               {}
+func (*ExecInput) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{1} }
+
+func (m *ExecInput) GetRequest() *ExecRequest {
+	if m != nil {
+		return m.Request
+	}
+	return nil
+}
+
+func (m *ExecInput) GetStdin() []byte {
+	if m != nil {
+		return m.Stdin
+	}
+	return nil
+}
+
+func (m *ExecInput) GetEof() bool {
+	if m != nil {
+		return m.Eof
+	}
+	return false
+}
+
+func (m *ExecInput) GetResize() *WindowSize {
+	if m != nil {
+		return m.Resize
+	}
+	return nil
+}
+
 // Response message
 type ExecReply struct {
 	Output []byte `protobuf:"bytes,1,opt,name=output,proto3" json:"output,omitempty"`
+	// status is only set on the final message of the stream, once the
+	// remote process has terminated.
+	Status *ExecStatus      `protobuf:"bytes,2,opt,name=status" json:"status,omitempty"`
+	Stream ExecReply_Stream `protobuf:"varint,3,opt,name=stream,enum=admin.ExecReply_Stream" json:"stream,omitempty"`
+	// truncated marks where the output was cut off as the remote process
+	// wrote more than it may. No more output follows it.
+	Truncated bool `protobuf:"varint,4,opt,name=truncated" json:"truncated,omitempty"`
 }
 
 func (m *ExecReply) Reset()                    { *m = ExecReply{} }
 func (m *ExecReply) String() string            { return proto.CompactTextString(m) }
 func (*ExecReply) ProtoMessage()               {}
-func (*ExecReply) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{1} }
+func (*ExecReply) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{2} }
 
 func (m *ExecReply) GetOutput() []byte {
 	if m != nil {
@@ -76,9 +243,712 @@ func (m *ExecReply) GetOutput() []byte {
 	return nil
 }
 
+func (m *ExecReply) GetStatus() *ExecStatus {
+	if m != nil {
+		return m.Status
+	}
+	return nil
+}
+
+func (m *ExecReply) GetStream() ExecReply_Stream {
+	if m != nil {
+		return m.Stream
+	}
+	return ExecReply_STDOUT
+}
+
+func (m *ExecReply) GetTruncated() bool {
+	if m != nil {
+		return m.Truncated
+	}
+	return false
+}
+
+// How the remote process terminated
+type ExecStatus struct {
+	// exitCode follows the shell convention. If the process was killed by a
+	// signal it is 128 + the signal number.
+	ExitCode int32 `protobuf:"varint,1,opt,name=exitCode" json:"exitCode,omitempty"`
+	// signal is the name of the signal which killed the process, if any.
+	Signal         string `protobuf:"bytes,2,opt,name=signal" json:"signal,omitempty"`
+	WallTimeUsec   int64  `protobuf:"varint,3,opt,name=wallTimeUsec" json:"wallTimeUsec,omitempty"`
+	UserTimeUsec   int64  `protobuf:"varint,4,opt,name=userTimeUsec" json:"userTimeUsec,omitempty"`
+	SystemTimeUsec int64  `protobuf:"varint,5,opt,name=systemTimeUsec" json:"systemTimeUsec,omitempty"`
+	// maxRssKb is the maximum resident set size in kilobytes.
+	MaxRssKb               int64 `protobuf:"varint,6,opt,name=maxRssKb" json:"maxRssKb,omitempty"`
+	MinorFaults            int64 `protobuf:"varint,7,opt,name=minorFaults" json:"minorFaults,omitempty"`
+	MajorFaults            int64 `protobuf:"varint,8,opt,name=majorFaults" json:"majorFaults,omitempty"`
+	InBlocks               int64 `protobuf:"varint,9,opt,name=inBlocks" json:"inBlocks,omitempty"`
+	OutBlocks              int64 `protobuf:"varint,10,opt,name=outBlocks" json:"outBlocks,omitempty"`
+	VoluntaryCtxSwitches   int64 `protobuf:"varint,11,opt,name=voluntaryCtxSwitches" json:"voluntaryCtxSwitches,omitempty"`
+	InvoluntaryCtxSwitches int64 `protobuf:"varint,12,opt,name=involuntaryCtxSwitches" json:"involuntaryCtxSwitches,omitempty"`
+	// timedOut is set if the process was terminated because it ran too long
+	TimedOut bool `protobuf:"varint,13,opt,name=timedOut" json:"timedOut,omitempty"`
+	// reason is the limit the process was killed for breaching, if any,
+	// MemoryLimit, PidsLimit or OutputLimit
+	Reason string `protobuf:"bytes,14,opt,name=reason" json:"reason,omitempty"`
+}
+
+func (m *ExecStatus) Reset()                    { *m = ExecStatus{} }
+func (m *ExecStatus) String() string            { return proto.CompactTextString(m) }
+func (*ExecStatus) ProtoMessage()               {}
+func (*ExecStatus) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{3} }
+
+func (m *ExecStatus) GetExitCode() int32 {
+	if m != nil {
+		return m.ExitCode
+	}
+	return 0
+}
+
+func (m *ExecStatus) GetSignal() string {
+	if m != nil {
+		return m.Signal
+	}
+	return ""
+}
+
+func (m *ExecStatus) GetWallTimeUsec() int64 {
+	if m != nil {
+		return m.WallTimeUsec
+	}
+	return 0
+}
+
+func (m *ExecStatus) GetUserTimeUsec() int64 {
+	if m != nil {
+		return m.UserTimeUsec
+	}
+	return 0
+}
+
+func (m *ExecStatus) GetSystemTimeUsec() int64 {
+	if m != nil {
+		return m.SystemTimeUsec
+	}
+	return 0
+}
+
+func (m *ExecStatus) GetMaxRssKb() int64 {
+	if m != nil {
+		return m.MaxRssKb
+	}
+	return 0
+}
+
+func (m *ExecStatus) GetMinorFaults() int64 {
+	if m != nil {
+		return m.MinorFaults
+	}
+	return 0
+}
+
+func (m *ExecStatus) GetMajorFaults() int64 {
+	if m != nil {
+		return m.MajorFaults
+	}
+	return 0
+}
+
+func (m *ExecStatus) GetInBlocks() int64 {
+	if m != nil {
+		return m.InBlocks
+	}
+	return 0
+}
+
+func (m *ExecStatus) GetOutBlocks() int64 {
+	if m != nil {
+		return m.OutBlocks
+	}
+	return 0
+}
+
+func (m *ExecStatus) GetVoluntaryCtxSwitches() int64 {
+	if m != nil {
+		return m.VoluntaryCtxSwitches
+	}
+	return 0
+}
+
+func (m *ExecStatus) GetInvoluntaryCtxSwitches() int64 {
+	if m != nil {
+		return m.InvoluntaryCtxSwitches
+	}
+	return 0
+}
+
+func (m *ExecStatus) GetTimedOut() bool {
+	if m != nil {
+		return m.TimedOut
+	}
+	return false
+}
+
+func (m *ExecStatus) GetReason() string {
+	if m != nil {
+		return m.Reason
+	}
+	return ""
+}
+
+// Size of a terminal in characters
+type WindowSize struct {
+	Rows uint32 `protobuf:"varint,1,opt,name=rows" json:"rows,omitempty"`
+	Cols uint32 `protobuf:"varint,2,opt,name=cols" json:"cols,omitempty"`
+}
+
+func (m *WindowSize) Reset()                    { *m = WindowSize{} }
+func (m *WindowSize) String() string            { return proto.CompactTextString(m) }
+func (*WindowSize) ProtoMessage()               {}
+func (*WindowSize) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{4} }
+
+func (m *WindowSize) GetRows() uint32 {
+	if m != nil {
+		return m.Rows
+	}
+	return 0
+}
+
+func (m *WindowSize) GetCols() uint32 {
+	if m != nil {
+		return m.Cols
+	}
+	return 0
+}
+
+type ListCommandsRequest struct {
+}
+
+func (m *ListCommandsRequest) Reset()                    { *m = ListCommandsRequest{} }
+func (m *ListCommandsRequest) String() string            { return proto.CompactTextString(m) }
+func (*ListCommandsRequest) ProtoMessage()               {}
+func (*ListCommandsRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{5} }
+
+type ListCommandsReply struct {
+	Commands []*CommandInfo `protobuf:"bytes,1,rep,name=commands" json:"commands,omitempty"`
+}
+
+func (m *ListCommandsReply) Reset()                    { *m = ListCommandsReply{} }
+func (m *ListCommandsReply) String() string            { return proto.CompactTextString(m) }
+func (*ListCommandsReply) ProtoMessage()               {}
+func (*ListCommandsReply) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{6} }
+
+func (m *ListCommandsReply) GetCommands() []*CommandInfo {
+	if m != nil {
+		return m.Commands
+	}
+	return nil
+}
+
+// A command the caller may run. The same cmdName may be listed more than
+// once, each permitting different arguments.
+type CommandInfo struct {
+	CmdName             string      `protobuf:"bytes,1,opt,name=cmdName" json:"cmdName,omitempty"`
+	RequiredFlags       []string    `protobuf:"bytes,2,rep,name=requiredFlags" json:"requiredFlags,omitempty"`
+	PermittedShortFlags []string    `protobuf:"bytes,3,rep,name=permittedShortFlags" json:"permittedShortFlags,omitempty"`
+	PermittedLongFlags  []*LongFlag `protobuf:"bytes,4,rep,name=permittedLongFlags" json:"permittedLongFlags,omitempty"`
+	PermittedNouns      []string    `protobuf:"bytes,5,rep,name=permittedNouns" json:"permittedNouns,omitempty"`
+	Stdin               bool        `protobuf:"varint,6,opt,name=stdin" json:"stdin,omitempty"`
+	Tty                 bool        `protobuf:"varint,7,opt,name=tty" json:"tty,omitempty"`
+	Timeout             string      `protobuf:"bytes,8,opt,name=timeout" json:"timeout,omitempty"`
+	// subcommands lists the verbs of a multi-verb tool. Their cmdName is the
+	// name of the subcommand.
+	Subcommands []*CommandInfo `protobuf:"bytes,9,rep,name=subcommands" json:"subcommands,omitempty"`
+	FlagArgs    []*FlagArg     `protobuf:"bytes,10,rep,name=flagArgs" json:"flagArgs,omitempty"`
+	Positionals []*Positional  `protobuf:"bytes,11,rep,name=positionals" json:"positionals,omitempty"`
+	// maxArgs and maxArgLength are only set on the command, 0 means no limit
+	MaxArgs      uint32 `protobuf:"varint,12,opt,name=maxArgs" json:"maxArgs,omitempty"`
+	MaxArgLength uint32 `protobuf:"varint,13,opt,name=maxArgLength" json:"maxArgLength,omitempty"`
+	// denied flags, nouns and flag values override everything permitted
+	DeniedFlags      []string    `protobuf:"bytes,14,rep,name=deniedFlags" json:"deniedFlags,omitempty"`
+	DeniedNouns      []string    `protobuf:"bytes,15,rep,name=deniedNouns" json:"deniedNouns,omitempty"`
+	DeniedFlagValues []*LongFlag `protobuf:"bytes,16,rep,name=deniedFlagValues" json:"deniedFlagValues,omitempty"`
+	// aliases are other names the command may be run as
+	Aliases []string `protobuf:"bytes,17,rep,name=aliases" json:"aliases,omitempty"`
+	// binaryPath is what is run on the host, if it is not cmdName, with
+	// prependArgs before and appendArgs after the arguments of the request
+	BinaryPath  string   `protobuf:"bytes,18,opt,name=binaryPath" json:"binaryPath,omitempty"`
+	PrependArgs []string `protobuf:"bytes,19,rep,name=prependArgs" json:"prependArgs,omitempty"`
+	AppendArgs  []string `protobuf:"bytes,20,rep,name=appendArgs" json:"appendArgs,omitempty"`
+	// hostNamespaces are the namespaces of the host the command runs in, it
+	// runs in the namespaces of the server for all others
+	HostNamespaces []string `protobuf:"bytes,21,rep,name=hostNamespaces" json:"hostNamespaces,omitempty"`
+	// targetNamespaces are the namespaces of the target the command runs
+	// in. If set the command may only be run in a target.
+	TargetNamespaces []string `protobuf:"bytes,22,rep,name=targetNamespaces" json:"targetNamespaces,omitempty"`
+}
+
+func (m *CommandInfo) Reset()                    { *m = CommandInfo{} }
+func (m *CommandInfo) String() string            { return proto.CompactTextString(m) }
+func (*CommandInfo) ProtoMessage()               {}
+func (*CommandInfo) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{7} }
+
+func (m *CommandInfo) GetCmdName() string {
+	if m != nil {
+		return m.CmdName
+	}
+	return ""
+}
+
+func (m *CommandInfo) GetRequiredFlags() []string {
+	if m != nil {
+		return m.RequiredFlags
+	}
+	return nil
+}
+
+func (m *CommandInfo) GetPermittedShortFlags() []string {
+	if m != nil {
+		return m.PermittedShortFlags
+	}
+	return nil
+}
+
+func (m *CommandInfo) GetPermittedLongFlags() []*LongFlag {
+	if m != nil {
+		return m.PermittedLongFlags
+	}
+	return nil
+}
+
+func (m *CommandInfo) GetPermittedNouns() []string {
+	if m != nil {
+		return m.PermittedNouns
+	}
+	return nil
+}
+
+func (m *CommandInfo) GetStdin() bool {
+	if m != nil {
+		return m.Stdin
+	}
+	return false
+}
+
+func (m *CommandInfo) GetTty() bool {
+	if m != nil {
+		return m.Tty
+	}
+	return false
+}
+
+func (m *CommandInfo) GetTimeout() string {
+	if m != nil {
+		return m.Timeout
+	}
+	return ""
+}
+
+func (m *CommandInfo) GetSubcommands() []*CommandInfo {
+	if m != nil {
+		return m.Subcommands
+	}
+	return nil
+}
+
+func (m *CommandInfo) GetFlagArgs() []*FlagArg {
+	if m != nil {
+		return m.FlagArgs
+	}
+	return nil
+}
+
+func (m *CommandInfo) GetPositionals() []*Positional {
+	if m != nil {
+		return m.Positionals
+	}
+	return nil
+}
+
+func (m *CommandInfo) GetMaxArgs() uint32 {
+	if m != nil {
+		return m.MaxArgs
+	}
+	return 0
+}
+
+func (m *CommandInfo) GetMaxArgLength() uint32 {
+	if m != nil {
+		return m.MaxArgLength
+	}
+	return 0
+}
+
+func (m *CommandInfo) GetDeniedFlags() []string {
+	if m != nil {
+		return m.DeniedFlags
+	}
+	return nil
+}
+
+func (m *CommandInfo) GetDeniedNouns() []string {
+	if m != nil {
+		return m.DeniedNouns
+	}
+	return nil
+}
+
+func (m *CommandInfo) GetDeniedFlagValues() []*LongFlag {
+	if m != nil {
+		return m.DeniedFlagValues
+	}
+	return nil
+}
+
+func (m *CommandInfo) GetAliases() []string {
+	if m != nil {
+		return m.Aliases
+	}
+	return nil
+}
+
+func (m *CommandInfo) GetBinaryPath() string {
+	if m != nil {
+		return m.BinaryPath
+	}
+	return ""
+}
+
+func (m *CommandInfo) GetPrependArgs() []string {
+	if m != nil {
+		return m.PrependArgs
+	}
+	return nil
+}
+
+func (m *CommandInfo) GetAppendArgs() []string {
+	if m != nil {
+		return m.AppendArgs
+	}
+	return nil
+}
+
+func (m *CommandInfo) GetHostNamespaces() []string {
+	if m != nil {
+		return m.HostNamespaces
+	}
+	return nil
+}
+
+func (m *CommandInfo) GetTargetNamespaces() []string {
+	if m != nil {
+		return m.TargetNamespaces
+	}
+	return nil
+}
+
+// A flag and the patterns its value must, or for deniedFlagValues must not,
+// match
+type LongFlag struct {
+	Name   string   `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	Values []string `protobuf:"bytes,2,rep,name=values" json:"values,omitempty"`
+}
+
+func (m *LongFlag) Reset()                    { *m = LongFlag{} }
+func (m *LongFlag) String() string            { return proto.CompactTextString(m) }
+func (*LongFlag) ProtoMessage()               {}
+func (*LongFlag) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{8} }
+
+func (m *LongFlag) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *LongFlag) GetValues() []string {
+	if m != nil {
+		return m.Values
+	}
+	return nil
+}
+
+type ValidateExecReply struct {
+	// allowed is set if at least one rule permits the request
+	Allowed bool          `protobuf:"varint,1,opt,name=allowed" json:"allowed,omitempty"`
+	Rules   []*RuleResult `protobuf:"bytes,2,rep,name=rules" json:"rules,omitempty"`
+}
+
+func (m *ValidateExecReply) Reset()                    { *m = ValidateExecReply{} }
+func (m *ValidateExecReply) String() string            { return proto.CompactTextString(m) }
+func (*ValidateExecReply) ProtoMessage()               {}
+func (*ValidateExecReply) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{9} }
+
+func (m *ValidateExecReply) GetAllowed() bool {
+	if m != nil {
+		return m.Allowed
+	}
+	return false
+}
+
+func (m *ValidateExecReply) GetRules() []*RuleResult {
+	if m != nil {
+		return m.Rules
+	}
+	return nil
+}
+
+// How one of the rules for a command judged the request
+type RuleResult struct {
+	// rule is only set if the caller is authorized to use it, otherwise the
+	// only check is the failed AUTHZ
+	Rule    *CommandInfo   `protobuf:"bytes,1,opt,name=rule" json:"rule,omitempty"`
+	Allowed bool           `protobuf:"varint,2,opt,name=allowed" json:"allowed,omitempty"`
+	Checks  []*CheckResult `protobuf:"bytes,3,rep,name=checks" json:"checks,omitempty"`
+}
+
+func (m *RuleResult) Reset()                    { *m = RuleResult{} }
+func (m *RuleResult) String() string            { return proto.CompactTextString(m) }
+func (*RuleResult) ProtoMessage()               {}
+func (*RuleResult) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{10} }
+
+func (m *RuleResult) GetRule() *CommandInfo {
+	if m != nil {
+		return m.Rule
+	}
+	return nil
+}
+
+func (m *RuleResult) GetAllowed() bool {
+	if m != nil {
+		return m.Allowed
+	}
+	return false
+}
+
+func (m *RuleResult) GetChecks() []*CheckResult {
+	if m != nil {
+		return m.Checks
+	}
+	return nil
+}
+
+// The outcome of checking one part of the request against a rule
+type CheckResult struct {
+	Kind CheckResult_Kind `protobuf:"varint,1,opt,name=kind,enum=admin.CheckResult_Kind" json:"kind,omitempty"`
+	// arg is the argument, flag or permission which was checked
+	Arg    string `protobuf:"bytes,2,opt,name=arg" json:"arg,omitempty"`
+	Passed bool   `protobuf:"varint,3,opt,name=passed" json:"passed,omitempty"`
+	// message explains why the check failed
+	Message string `protobuf:"bytes,4,opt,name=message" json:"message,omitempty"`
+}
+
+func (m *CheckResult) Reset()                    { *m = CheckResult{} }
+func (m *CheckResult) String() string            { return proto.CompactTextString(m) }
+func (*CheckResult) ProtoMessage()               {}
+func (*CheckResult) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{11} }
+
+func (m *CheckResult) GetKind() CheckResult_Kind {
+	if m != nil {
+		return m.Kind
+	}
+	return CheckResult_SYNTAX
+}
+
+func (m *CheckResult) GetArg() string {
+	if m != nil {
+		return m.Arg
+	}
+	return ""
+}
+
+func (m *CheckResult) GetPassed() bool {
+	if m != nil {
+		return m.Passed
+	}
+	return false
+}
+
+func (m *CheckResult) GetMessage() string {
+	if m != nil {
+		return m.Message
+	}
+	return ""
+}
+
+// A short or long flag which takes a value
+type FlagArg struct {
+	Name string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	// arg is one of none, optional or required
+	Arg string `protobuf:"bytes,2,opt,name=arg" json:"arg,omitempty"`
+	// values are the patterns the value must match
+	Values []string  `protobuf:"bytes,3,rep,name=values" json:"values,omitempty"`
+	Path   *PathRule `protobuf:"bytes,4,opt,name=path" json:"path,omitempty"`
+}
+
+func (m *FlagArg) Reset()                    { *m = FlagArg{} }
+func (m *FlagArg) String() string            { return proto.CompactTextString(m) }
+func (*FlagArg) ProtoMessage()               {}
+func (*FlagArg) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{12} }
+
+func (m *FlagArg) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *FlagArg) GetArg() string {
+	if m != nil {
+		return m.Arg
+	}
+	return ""
+}
+
+func (m *FlagArg) GetValues() []string {
+	if m != nil {
+		return m.Values
+	}
+	return nil
+}
+
+func (m *FlagArg) GetPath() *PathRule {
+	if m != nil {
+		return m.Path
+	}
+	return nil
+}
+
+// A slot of the non-flag arguments of a command
+type Positional struct {
+	Name string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	// values are the patterns every argument in the slot must match
+	Values []string `protobuf:"bytes,2,rep,name=values" json:"values,omitempty"`
+	// min and max are how many arguments the slot takes, max -1 means no limit
+	Min  uint32    `protobuf:"varint,3,opt,name=min" json:"min,omitempty"`
+	Max  int32     `protobuf:"varint,4,opt,name=max" json:"max,omitempty"`
+	Path *PathRule `protobuf:"bytes,5,opt,name=path" json:"path,omitempty"`
+}
+
+func (m *Positional) Reset()                    { *m = Positional{} }
+func (m *Positional) String() string            { return proto.CompactTextString(m) }
+func (*Positional) ProtoMessage()               {}
+func (*Positional) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{13} }
+
+func (m *Positional) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *Positional) GetValues() []string {
+	if m != nil {
+		return m.Values
+	}
+	return nil
+}
+
+func (m *Positional) GetMin() uint32 {
+	if m != nil {
+		return m.Min
+	}
+	return 0
+}
+
+func (m *Positional) GetMax() int32 {
+	if m != nil {
+		return m.Max
+	}
+	return 0
+}
+
+func (m *Positional) GetPath() *PathRule {
+	if m != nil {
+		return m.Path
+	}
+	return nil
+}
+
+// Directories an argument which is a path must resolve under
+type PathRule struct {
+	Allow []string `protobuf:"bytes,1,rep,name=allow" json:"allow,omitempty"`
+	Deny  []string `protobuf:"bytes,2,rep,name=deny" json:"deny,omitempty"`
+}
+
+func (m *PathRule) Reset()                    { *m = PathRule{} }
+func (m *PathRule) String() string            { return proto.CompactTextString(m) }
+func (*PathRule) ProtoMessage()               {}
+func (*PathRule) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{14} }
+
+func (m *PathRule) GetAllow() []string {
+	if m != nil {
+		return m.Allow
+	}
+	return nil
+}
+
+func (m *PathRule) GetDeny() []string {
+	if m != nil {
+		return m.Deny
+	}
+	return nil
+}
+
+// The pod, or container, a command runs in
+type Target struct {
+	Namespace string `protobuf:"bytes,1,opt,name=namespace" json:"namespace,omitempty"`
+	Pod       string `protobuf:"bytes,2,opt,name=pod" json:"pod,omitempty"`
+	// container is the name of the container in the pod, the first
+	// container of the pod if not set
+	Container string `protobuf:"bytes,3,opt,name=container" json:"container,omitempty"`
+	// containerID may be set instead of namespace and pod
+	ContainerID string `protobuf:"bytes,4,opt,name=containerID" json:"containerID,omitempty"`
+}
+
+func (m *Target) Reset()                    { *m = Target{} }
+func (m *Target) String() string            { return proto.CompactTextString(m) }
+func (*Target) ProtoMessage()               {}
+func (*Target) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{15} }
+
+func (m *Target) GetNamespace() string {
+	if m != nil {
+		return m.Namespace
+	}
+	return ""
+}
+
+func (m *Target) GetPod() string {
+	if m != nil {
+		return m.Pod
+	}
+	return ""
+}
+
+func (m *Target) GetContainer() string {
+	if m != nil {
+		return m.Container
+	}
+	return ""
+}
+
+func (m *Target) GetContainerID() string {
+	if m != nil {
+		return m.ContainerID
+	}
+	return ""
+}
+
 func init() {
 	proto.RegisterType((*ExecRequest)(nil), "admin.ExecRequest")
+	proto.RegisterType((*ExecInput)(nil), "admin.ExecInput")
 	proto.RegisterType((*ExecReply)(nil), "admin.ExecReply")
+	proto.RegisterType((*ExecStatus)(nil), "admin.ExecStatus")
+	proto.RegisterType((*WindowSize)(nil), "admin.WindowSize")
+	proto.RegisterType((*ListCommandsRequest)(nil), "admin.ListCommandsRequest")
+	proto.RegisterType((*ListCommandsReply)(nil), "admin.ListCommandsReply")
+	proto.RegisterType((*CommandInfo)(nil), "admin.CommandInfo")
+	proto.RegisterType((*LongFlag)(nil), "admin.LongFlag")
+	proto.RegisterType((*ValidateExecReply)(nil), "admin.ValidateExecReply")
+	proto.RegisterType((*RuleResult)(nil), "admin.RuleResult")
+	proto.RegisterType((*CheckResult)(nil), "admin.CheckResult")
+	proto.RegisterType((*FlagArg)(nil), "admin.FlagArg")
+	proto.RegisterType((*Positional)(nil), "admin.Positional")
+	proto.RegisterType((*PathRule)(nil), "admin.PathRule")
+	proto.RegisterType((*Target)(nil), "admin.Target")
+	proto.RegisterEnum("admin.ExecReply_Stream", ExecReply_Stream_name, ExecReply_Stream_value)
+	proto.RegisterEnum("admin.CheckResult_Kind", CheckResult_Kind_name, CheckResult_Kind_value)
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -94,6 +964,13 @@ const _ = grpc.SupportPackageIsVersion4
 type ExecClient interface {
 	// Send a single command to be executed
 	SendExec(ctx context.Context, in *ExecRequest, opts ...grpc.CallOption) (Exec_SendExecClient, error)
+	// Run a command which reads from stdin. The first message must carry the
+	// request, every later message carries input for the command.
+	StreamExec(ctx context.Context, opts ...grpc.CallOption) (Exec_StreamExecClient, error)
+	// List the commands the caller is allowed to run
+	ListCommands(ctx context.Context, in *ListCommandsRequest, opts ...grpc.CallOption) (*ListCommandsReply, error)
+	// Check a request against every rule for the command without running it
+	ValidateExec(ctx context.Context, in *ExecRequest, opts ...grpc.CallOption) (*ValidateExecReply, error)
 }
 
 type execClient struct {
@@ -136,11 +1013,67 @@ func (x *execSendExecClient) Recv() (*ExecReply, error) {
 	return m, nil
 }
 
+func (c *execClient) StreamExec(ctx context.Context, opts ...grpc.CallOption) (Exec_StreamExecClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_Exec_serviceDesc.Streams[1], c.cc, "/admin.Exec/StreamExec", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &execStreamExecClient{stream}
+	return x, nil
+}
+
+type Exec_StreamExecClient interface {
+	Send(*ExecInput) error
+	Recv() (*ExecReply, error)
+	grpc.ClientStream
+}
+
+type execStreamExecClient struct {
+	grpc.ClientStream
+}
+
+func (x *execStreamExecClient) Send(m *ExecInput) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *execStreamExecClient) Recv() (*ExecReply, error) {
+	m := new(ExecReply)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *execClient) ListCommands(ctx context.Context, in *ListCommandsRequest, opts ...grpc.CallOption) (*ListCommandsReply, error) {
+	out := new(ListCommandsReply)
+	err := grpc.Invoke(ctx, "/admin.Exec/ListCommands", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *execClient) ValidateExec(ctx context.Context, in *ExecRequest, opts ...grpc.CallOption) (*ValidateExecReply, error) {
+	out := new(ValidateExecReply)
+	err := grpc.Invoke(ctx, "/admin.Exec/ValidateExec", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // Server API for Exec service
 
 type ExecServer interface {
 	// Send a single command to be executed
 	SendExec(*ExecRequest, Exec_SendExecServer) error
+	// Run a command which reads from stdin. The first message must carry the
+	// request, every later message carries input for the command.
+	StreamExec(Exec_StreamExecServer) error
+	// List the commands the caller is allowed to run
+	ListCommands(context.Context, *ListCommandsRequest) (*ListCommandsReply, error)
+	// Check a request against every rule for the command without running it
+	ValidateExec(context.Context, *ExecRequest) (*ValidateExecReply, error)
 }
 
 func RegisterExecServer(s *grpc.Server, srv ExecServer) {
@@ -168,16 +1101,93 @@ func (x *execSendExecServer) Send(m *ExecReply) error {
 	return x.ServerStream.SendMsg(m)
 }
 
+func _Exec_StreamExec_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(ExecServer).StreamExec(&execStreamExecServer{stream})
+}
+
+type Exec_StreamExecServer interface {
+	Send(*ExecReply) error
+	Recv() (*ExecInput, error)
+	grpc.ServerStream
+}
+
+type execStreamExecServer struct {
+	grpc.ServerStream
+}
+
+func (x *execStreamExecServer) Send(m *ExecReply) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *execStreamExecServer) Recv() (*ExecInput, error) {
+	m := new(ExecInput)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _Exec_ListCommands_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListCommandsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ExecServer).ListCommands(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/admin.Exec/ListCommands",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ExecServer).ListCommands(ctx, req.(*ListCommandsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Exec_ValidateExec_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ExecRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ExecServer).ValidateExec(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/admin.Exec/ValidateExec",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ExecServer).ValidateExec(ctx, req.(*ExecRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 var _Exec_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "admin.Exec",
 	HandlerType: (*ExecServer)(nil),
-	Methods:     []grpc.MethodDesc{},
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListCommands",
+			Handler:    _Exec_ListCommands_Handler,
+		},
+		{
+			MethodName: "ValidateExec",
+			Handler:    _Exec_ValidateExec_Handler,
+		},
+	},
 	Streams: []grpc.StreamDesc{
 		{
 			StreamName:    "SendExec",
 			Handler:       _Exec_SendExec_Handler,
 			ServerStreams: true,
 		},
+		{
+			StreamName:    "StreamExec",
+			Handler:       _Exec_StreamExec_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
 	},
 	Metadata: "api/services.proto",
 }
@@ -185,18 +1195,95 @@ var _Exec_serviceDesc = grpc.ServiceDesc{
 func init() { proto.RegisterFile("api/services.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 206 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xe2, 0x12, 0x4a, 0x2c, 0xc8, 0xd4,
-	0x2f, 0x4e, 0x2d, 0x2a, 0xcb, 0x4c, 0x4e, 0x2d, 0xd6, 0x2b, 0x28, 0xca, 0x2f, 0xc9, 0x17, 0x62,
-	0x4d, 0x4c, 0xc9, 0xcd, 0xcc, 0x93, 0x92, 0x49, 0xcf, 0xcf, 0x4f, 0xcf, 0x49, 0xd5, 0x07, 0xa9,
-	0x48, 0xcc, 0xcb, 0xcb, 0x2f, 0x49, 0x2c, 0xc9, 0xcc, 0xcf, 0x83, 0x2a, 0x52, 0x72, 0xe4, 0xe2,
-	0x76, 0xad, 0x48, 0x4d, 0x0e, 0x4a, 0x2d, 0x2c, 0x4d, 0x2d, 0x2e, 0x11, 0x92, 0xe0, 0x62, 0x4f,
-	0xce, 0x4d, 0xf1, 0x4b, 0xcc, 0x4d, 0x95, 0x60, 0x54, 0x60, 0xd4, 0xe0, 0x0c, 0x82, 0x71, 0xa1,
-	0x32, 0x8e, 0x45, 0xe9, 0xc5, 0x12, 0x4c, 0x0a, 0xcc, 0x50, 0x19, 0x10, 0x57, 0x49, 0x99, 0x8b,
-	0x13, 0x62, 0x44, 0x41, 0x4e, 0xa5, 0x90, 0x18, 0x17, 0x5b, 0x7e, 0x69, 0x49, 0x41, 0x69, 0x09,
-	0x58, 0x3f, 0x4f, 0x10, 0x94, 0x67, 0xe4, 0xcf, 0xc5, 0x02, 0x52, 0x24, 0xe4, 0xce, 0xc5, 0x11,
-	0x9c, 0x9a, 0x97, 0x02, 0x66, 0x0b, 0xe9, 0x81, 0x5d, 0xa8, 0x87, 0xe4, 0x00, 0x29, 0x01, 0x14,
-	0xb1, 0x82, 0x9c, 0x4a, 0x25, 0xe1, 0xa6, 0xcb, 0x4f, 0x26, 0x33, 0xf1, 0x2a, 0x71, 0xe8, 0x97,
-	0x19, 0xea, 0xa7, 0x56, 0xa4, 0x26, 0x5b, 0x31, 0x6a, 0x19, 0x30, 0x26, 0xb1, 0x81, 0xdd, 0x6f,
-	0x0c, 0x08, 0x00, 0x00, 0xff, 0xff, 0xbf, 0x10, 0x76, 0xd1, 0xfa, 0x00, 0x00, 0x00,
+	// 1439 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x94, 0x57, 0x4b, 0x4f, 0x1c, 0xc7,
+	0x16, 0x76, 0x33, 0x0f, 0x66, 0xce, 0xcc, 0xe0, 0xa1, 0xc0, 0xdc, 0x16, 0xb2, 0xae, 0x46, 0x7d,
+	0xef, 0xf5, 0x25, 0x24, 0x02, 0x1b, 0x5b, 0x5e, 0x24, 0x8b, 0x08, 0xf3, 0x32, 0x32, 0x06, 0xa7,
+	0x66, 0x70, 0x12, 0x6f, 0xa2, 0xa2, 0xbb, 0x18, 0x3a, 0xee, 0xae, 0xea, 0x74, 0x55, 0xc3, 0xe0,
+	0x5d, 0xb2, 0xce, 0x2e, 0x3f, 0xc8, 0x7f, 0x21, 0x52, 0x94, 0x7f, 0x90, 0x55, 0xf6, 0xd9, 0x47,
+	0xf5, 0xe8, 0xc7, 0xc0, 0xd8, 0x52, 0x76, 0x75, 0xbe, 0xf3, 0xd5, 0xe9, 0x73, 0xaa, 0xce, 0xa3,
+	0x1a, 0x10, 0x49, 0xc2, 0x4d, 0x41, 0xd3, 0xcb, 0xd0, 0xa7, 0x62, 0x23, 0x49, 0xb9, 0xe4, 0xa8,
+	0x41, 0x82, 0x38, 0x64, 0xab, 0xf7, 0xc7, 0x9c, 0x8f, 0x23, 0xba, 0xa9, 0x18, 0x84, 0x31, 0x2e,
+	0x89, 0x0c, 0x39, 0xb3, 0x24, 0xef, 0x77, 0x07, 0x3a, 0x7b, 0x13, 0xea, 0x63, 0xfa, 0x43, 0x46,
+	0x85, 0x44, 0x2e, 0xcc, 0xfb, 0x71, 0x70, 0x4c, 0x62, 0xea, 0x3a, 0x03, 0x67, 0xad, 0x8d, 0x73,
+	0xd1, 0x6a, 0xb6, 0xd3, 0xb1, 0x70, 0xe7, 0x06, 0x35, 0xab, 0x51, 0x22, 0xea, 0x43, 0x4d, 0xca,
+	0x6b, 0xb7, 0x36, 0x70, 0xd6, 0x5a, 0x58, 0x2d, 0xd1, 0x23, 0x80, 0xab, 0x90, 0x05, 0xfc, 0x6a,
+	0x18, 0xbe, 0xa3, 0x6e, 0x7d, 0xe0, 0xac, 0x75, 0xb6, 0x16, 0x37, 0xb4, 0x3f, 0x1b, 0x5f, 0x17,
+	0x0a, 0x5c, 0x21, 0xa1, 0x07, 0xb0, 0x20, 0xc3, 0x98, 0xf2, 0x4c, 0x0e, 0xa9, 0xcf, 0x59, 0x20,
+	0xdc, 0xc6, 0xc0, 0x59, 0xeb, 0xe1, 0x1b, 0x28, 0xfa, 0x1f, 0x34, 0x25, 0x49, 0xc7, 0x54, 0xba,
+	0x4d, 0x6d, 0xb6, 0x67, 0xcd, 0x8e, 0x34, 0x88, 0xad, 0xd2, 0xfb, 0xd9, 0x81, 0xb6, 0x8a, 0xeb,
+	0x90, 0x25, 0x99, 0x44, 0x9f, 0xc1, 0x7c, 0x6a, 0x02, 0xd4, 0x51, 0x75, 0xb6, 0x90, 0xdd, 0x55,
+	0x09, 0x1d, 0xe7, 0x14, 0xb4, 0x0c, 0x0d, 0x21, 0x83, 0x90, 0xb9, 0x73, 0x03, 0x67, 0xad, 0x8b,
+	0x8d, 0xa0, 0xa2, 0xa4, 0xfc, 0x3c, 0x8f, 0x92, 0xf2, 0x73, 0xf4, 0x09, 0x34, 0x53, 0x2a, 0x3e,
+	0x1a, 0xa1, 0x25, 0x78, 0xef, 0xad, 0x3b, 0x98, 0x26, 0xd1, 0x35, 0x5a, 0x81, 0x26, 0xcf, 0x64,
+	0x92, 0x19, 0x6f, 0xba, 0xd8, 0x4a, 0xca, 0xa0, 0x90, 0x44, 0x66, 0xc2, 0x9d, 0x9b, 0x32, 0xa8,
+	0x76, 0x0e, 0xb5, 0x02, 0x5b, 0x02, 0xda, 0x54, 0xd4, 0x94, 0x92, 0x58, 0x3b, 0xb4, 0xb0, 0xf5,
+	0xaf, 0xa9, 0x80, 0x92, 0xe8, 0x7a, 0x63, 0xa8, 0xd5, 0xd8, 0xd2, 0xd0, 0x7d, 0x68, 0xcb, 0x34,
+	0x63, 0x3e, 0x91, 0x34, 0xd0, 0xfe, 0xb6, 0x70, 0x09, 0x78, 0x03, 0x68, 0x1a, 0x3e, 0x02, 0x68,
+	0x0e, 0x47, 0xbb, 0x27, 0xa7, 0xa3, 0xfe, 0x1d, 0xbb, 0xde, 0xc3, 0xb8, 0xef, 0x78, 0x7f, 0xd6,
+	0x00, 0x4a, 0x3f, 0xd0, 0x2a, 0xb4, 0xe8, 0x24, 0x94, 0x3b, 0x3c, 0x30, 0x89, 0xd2, 0xc0, 0x85,
+	0xac, 0xc2, 0x13, 0xe1, 0x98, 0x91, 0x48, 0x87, 0xd1, 0xc6, 0x56, 0x42, 0x1e, 0x74, 0xaf, 0x48,
+	0x14, 0x8d, 0xc2, 0x98, 0x9e, 0x0a, 0xea, 0x6b, 0xcf, 0x6b, 0x78, 0x0a, 0x53, 0x9c, 0x4c, 0xd0,
+	0xb4, 0xe0, 0xd4, 0x0d, 0xa7, 0x8a, 0xa9, 0x54, 0x11, 0xd7, 0x42, 0xd2, 0xb8, 0x60, 0x35, 0x34,
+	0xeb, 0x06, 0xaa, 0x7c, 0x8c, 0xc9, 0x04, 0x0b, 0xf1, 0xe2, 0x4c, 0x27, 0x4b, 0x0d, 0x17, 0x32,
+	0x1a, 0x40, 0x27, 0x0e, 0x19, 0x4f, 0xf7, 0x49, 0x16, 0x49, 0xe1, 0xce, 0x6b, 0x75, 0x15, 0xd2,
+	0x0c, 0xf2, 0x7d, 0xc1, 0x68, 0x59, 0x46, 0x09, 0x29, 0xfb, 0x21, 0x7b, 0x16, 0x71, 0xff, 0xad,
+	0x70, 0xdb, 0xc6, 0x7e, 0x2e, 0xab, 0xe3, 0xe6, 0x99, 0xb4, 0x4a, 0xd0, 0xca, 0x12, 0x40, 0x5b,
+	0xb0, 0x7c, 0xc9, 0xa3, 0x8c, 0x49, 0x92, 0x5e, 0xef, 0xc8, 0xc9, 0xf0, 0x2a, 0x94, 0xfe, 0x05,
+	0x15, 0x6e, 0x47, 0x13, 0x67, 0xea, 0xd0, 0x53, 0x58, 0x09, 0xd9, 0xcc, 0x5d, 0x5d, 0xbd, 0xeb,
+	0x03, 0x5a, 0xe5, 0xa5, 0x2a, 0xa1, 0xe0, 0x24, 0x93, 0x6e, 0x4f, 0xdf, 0x7b, 0x21, 0xab, 0x9b,
+	0x4a, 0x29, 0x11, 0x9c, 0xb9, 0x0b, 0xe6, 0xa6, 0x8c, 0xe4, 0x3d, 0x01, 0x28, 0x93, 0x18, 0x21,
+	0xa8, 0xa7, 0xfc, 0x4a, 0xe8, 0x7b, 0xee, 0x61, 0xbd, 0x56, 0x98, 0xcf, 0x23, 0x93, 0xa8, 0x3d,
+	0xac, 0xd7, 0xde, 0x3d, 0x58, 0x3a, 0x0a, 0x85, 0xdc, 0xe1, 0x71, 0x4c, 0x58, 0x20, 0x6c, 0x5d,
+	0x79, 0x3b, 0xb0, 0x38, 0x0d, 0xab, 0x12, 0xd8, 0x80, 0x96, 0x6f, 0x01, 0xd7, 0x19, 0xd4, 0x2a,
+	0x25, 0x69, 0x79, 0x87, 0xec, 0x9c, 0xe3, 0x82, 0xe3, 0xfd, 0xda, 0x84, 0x4e, 0x45, 0xf3, 0x91,
+	0x3e, 0xf5, 0x5f, 0xe8, 0xa9, 0x42, 0x0e, 0x53, 0x1a, 0xec, 0x47, 0xa4, 0xe8, 0x56, 0xd3, 0x20,
+	0x7a, 0x08, 0x4b, 0x09, 0x4d, 0xe3, 0x50, 0x4a, 0x1a, 0x0c, 0x2f, 0x78, 0x2a, 0x0d, 0xb7, 0xa6,
+	0xb9, 0xb3, 0x54, 0xe8, 0x4b, 0x40, 0x05, 0x7c, 0xc4, 0xd9, 0xd8, 0x6c, 0xa8, 0x6b, 0xdf, 0xef,
+	0x5a, 0xdf, 0x73, 0x1c, 0xcf, 0xa0, 0xaa, 0xb4, 0x2d, 0xd0, 0x63, 0x9e, 0x31, 0xd5, 0xe1, 0xd4,
+	0xd7, 0x6e, 0xa0, 0x65, 0xfb, 0x69, 0xea, 0xdb, 0x2a, 0xdb, 0x8f, 0x6a, 0xb2, 0xf3, 0x65, 0x93,
+	0x75, 0x61, 0xde, 0xf6, 0x46, 0x9d, 0x9c, 0x6d, 0x9c, 0x8b, 0xe8, 0x09, 0x74, 0x44, 0x76, 0x56,
+	0x9c, 0x6f, 0xfb, 0x83, 0xe7, 0x5b, 0xa5, 0xa1, 0x75, 0x68, 0x9d, 0x47, 0x64, 0xac, 0x3b, 0x3c,
+	0xe8, 0x2d, 0x0b, 0x76, 0xcb, 0xbe, 0x81, 0x71, 0xa1, 0x47, 0x8f, 0xa1, 0x93, 0x70, 0x11, 0xaa,
+	0x49, 0x42, 0x22, 0x95, 0xb7, 0xb5, 0x4a, 0xbb, 0x7a, 0x55, 0x68, 0x70, 0x95, 0xa5, 0x1c, 0x8e,
+	0xc9, 0x44, 0xdb, 0xef, 0xea, 0xb4, 0xc9, 0x45, 0x55, 0xf5, 0x66, 0x79, 0x44, 0xd9, 0x58, 0x5e,
+	0xe8, 0x3c, 0xed, 0xe1, 0x29, 0x4c, 0xd5, 0x63, 0x40, 0x59, 0x98, 0xdf, 0xea, 0x82, 0x3e, 0xbb,
+	0x2a, 0x54, 0x32, 0xcc, 0xe9, 0xde, 0xad, 0x32, 0xcc, 0xd1, 0x7e, 0x01, 0xfd, 0x72, 0xc3, 0x6b,
+	0x12, 0x65, 0x54, 0xb8, 0xfd, 0xd9, 0x37, 0x78, 0x8b, 0xa8, 0xdc, 0x27, 0x51, 0x48, 0x04, 0x15,
+	0xee, 0xa2, 0x19, 0x80, 0x56, 0x44, 0xff, 0x06, 0x38, 0x0b, 0x19, 0x49, 0xaf, 0x5f, 0x11, 0x79,
+	0xe1, 0x22, 0x7d, 0x19, 0x15, 0x44, 0x39, 0x96, 0xa4, 0x34, 0xa1, 0xcc, 0x8c, 0xcf, 0x25, 0xe3,
+	0x58, 0x05, 0x52, 0x16, 0x48, 0x52, 0x10, 0x96, 0x35, 0xa1, 0x82, 0xa8, 0xdc, 0xb9, 0xe0, 0x42,
+	0xaa, 0x04, 0x17, 0x09, 0xf1, 0xa9, 0x70, 0xef, 0x99, 0xdc, 0x99, 0x46, 0xd1, 0x3a, 0xf4, 0xcd,
+	0x00, 0xac, 0x30, 0x57, 0x34, 0xf3, 0x16, 0xee, 0x3d, 0x85, 0x56, 0x1e, 0xad, 0x2a, 0x67, 0x56,
+	0xd6, 0x92, 0x5e, 0xab, 0xe6, 0x70, 0x69, 0x8e, 0xc8, 0x54, 0x90, 0x95, 0xbc, 0xd7, 0xb0, 0xf8,
+	0x9a, 0x44, 0x61, 0x40, 0x24, 0x2d, 0x47, 0x9a, 0x3e, 0x9c, 0x88, 0x5f, 0xd1, 0x40, 0xdb, 0x68,
+	0xe1, 0x5c, 0x44, 0xff, 0x87, 0x46, 0x9a, 0x45, 0xd6, 0x4a, 0x99, 0x24, 0x38, 0x8b, 0x28, 0xa6,
+	0x22, 0x8b, 0x24, 0x36, 0x7a, 0xef, 0x1d, 0x40, 0x09, 0xa2, 0x07, 0x50, 0x57, 0xf0, 0x8d, 0x79,
+	0x5d, 0x4d, 0x5e, 0xad, 0xaf, 0x7e, 0x78, 0x6e, 0xfa, 0xc3, 0xeb, 0xd0, 0xf4, 0x2f, 0xa8, 0xff,
+	0xd6, 0x54, 0x75, 0xc5, 0x86, 0x02, 0xed, 0xa7, 0x2d, 0xc3, 0xfb, 0xcb, 0x81, 0x4e, 0x05, 0x47,
+	0x9f, 0x42, 0xfd, 0x6d, 0xc8, 0x4c, 0x2c, 0xe5, 0x70, 0xad, 0x30, 0x36, 0x5e, 0x84, 0x2c, 0xc0,
+	0x9a, 0xa4, 0x4a, 0x93, 0xa4, 0x63, 0x3b, 0xec, 0xd4, 0x52, 0x1d, 0x5d, 0x42, 0x84, 0xa0, 0x81,
+	0x7d, 0x2e, 0x58, 0x49, 0x57, 0x00, 0x15, 0x82, 0x8c, 0xcd, 0x93, 0xa1, 0x8d, 0x73, 0xd1, 0xcb,
+	0xa0, 0xae, 0x2c, 0xea, 0x91, 0xfb, 0xed, 0xf1, 0x68, 0xfb, 0x9b, 0xfe, 0x1d, 0xb4, 0x00, 0x30,
+	0x7c, 0x7e, 0x82, 0x47, 0xdf, 0xed, 0x1f, 0x6d, 0x1f, 0xf4, 0x1d, 0xd4, 0x83, 0xf6, 0xd1, 0xc9,
+	0xf1, 0x81, 0x11, 0xe7, 0x50, 0x0b, 0xea, 0xc7, 0x27, 0xa7, 0xc7, 0xfd, 0x1a, 0x5a, 0x84, 0x1e,
+	0xde, 0xfb, 0xea, 0xf4, 0x10, 0xef, 0xed, 0x1a, 0x65, 0x1d, 0xb5, 0xa1, 0xb1, 0x7d, 0x3a, 0x7a,
+	0xfe, 0xa6, 0xdf, 0xd0, 0x66, 0x4e, 0x9f, 0xed, 0x9c, 0xbc, 0x7c, 0xb9, 0x7d, 0xbc, 0xdb, 0x6f,
+	0xaa, 0x4f, 0x8c, 0xb6, 0xf1, 0xc1, 0xde, 0xa8, 0x3f, 0xef, 0x45, 0x30, 0x6f, 0x8b, 0x7b, 0x66,
+	0x0a, 0xcc, 0x8c, 0xcc, 0x26, 0x45, 0xad, 0x9a, 0x14, 0xe8, 0x3f, 0x50, 0x4f, 0x54, 0xf2, 0x9b,
+	0x97, 0x50, 0x5e, 0x4d, 0x2a, 0xfb, 0xf5, 0x9d, 0x6a, 0xa5, 0xf7, 0xa3, 0x03, 0x50, 0x36, 0x87,
+	0x7f, 0x92, 0x74, 0xca, 0x93, 0x38, 0x64, 0xfa, 0x38, 0x7b, 0x58, 0x2d, 0x35, 0x42, 0x26, 0xfa,
+	0x83, 0x0d, 0xac, 0x96, 0x85, 0x0f, 0x8d, 0x8f, 0xf9, 0xf0, 0x04, 0x5a, 0x39, 0xa2, 0x3a, 0xad,
+	0x4e, 0x16, 0x3d, 0x81, 0xda, 0xd8, 0x08, 0xca, 0xad, 0x80, 0xb2, 0x6b, 0xeb, 0x80, 0x5e, 0x7b,
+	0x13, 0x68, 0x9a, 0x07, 0xa6, 0x1a, 0xec, 0x2c, 0xaf, 0x21, 0xeb, 0x79, 0x09, 0x28, 0xa7, 0x12,
+	0x1e, 0xe4, 0x07, 0x96, 0xf0, 0x40, 0xf1, 0x7d, 0xce, 0x24, 0x09, 0x19, 0x4d, 0xb5, 0xfb, 0x6d,
+	0x5c, 0x02, 0xaa, 0x33, 0x14, 0xc2, 0xe1, 0xae, 0x4d, 0x8a, 0x2a, 0xb4, 0xf5, 0x7e, 0x0e, 0xea,
+	0xaa, 0xcc, 0xd0, 0x01, 0xb4, 0x86, 0x94, 0x05, 0x7a, 0x3d, 0xe3, 0xf9, 0xba, 0xda, 0xbf, 0xf9,
+	0x02, 0xf4, 0x96, 0x7e, 0xfa, 0xed, 0x8f, 0x5f, 0xe6, 0x7a, 0x9f, 0x3b, 0xeb, 0x5e, 0x6b, 0xf3,
+	0xf2, 0xd1, 0x26, 0x9d, 0x50, 0xff, 0xa1, 0x83, 0x9e, 0x02, 0x98, 0xb7, 0x9e, 0x36, 0x55, 0xdd,
+	0xa6, 0x1f, 0xcb, 0x33, 0x0c, 0xdd, 0x59, 0x73, 0x1e, 0x3a, 0xe8, 0x0d, 0x74, 0xab, 0x73, 0x1c,
+	0xad, 0xe6, 0x2d, 0xf3, 0xf6, 0xcc, 0x5f, 0x75, 0x67, 0xea, 0x94, 0xad, 0x65, 0xed, 0xd4, 0x02,
+	0xea, 0x2a, 0x8f, 0x8a, 0xd9, 0xf3, 0x06, 0xba, 0xd5, 0x9e, 0x32, 0x33, 0xc0, 0xdc, 0xe6, 0xad,
+	0xe6, 0xe3, 0xdd, 0xd7, 0x36, 0x57, 0x54, 0xa0, 0x8b, 0x79, 0xa0, 0x9b, 0x97, 0x96, 0x76, 0xd6,
+	0xd4, 0x7f, 0x3a, 0x8f, 0xff, 0x1e, 0x00, 0xbd, 0x0b, 0x57, 0x7a, 0x24, 0x0d, 0x00, 0x00,
 }
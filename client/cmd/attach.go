@@ -0,0 +1,123 @@
+// Package client which will connect to a server and run a Go command.
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/crypto/ssh/terminal"
+
+	rpcapi "github.com/eparis/admin-rpc/api"
+)
+
+func init() {
+	attachCmd := &cobra.Command{
+		Use:   "attach --node=NODE Command [args]",
+		Short: "Run an interactive command on a terminal on the remote server",
+		RunE:  doAttach,
+	}
+	attachCmd.Flags().SetInterspersed(false)
+	addNodeFlag(attachCmd)
+	addTimeoutFlag(attachCmd)
+	addTargetFlags(attachCmd)
+	rootCmd.AddCommand(attachCmd)
+}
+
+// lockedStream allows stdin and terminal resizes to be sent from different
+// goroutines, as grpc streams are not safe for concurrent Send()
+type lockedStream struct {
+	sync.Mutex
+	stream rpcapi.Exec_StreamExecClient
+}
+
+func (ls *lockedStream) Send(in *rpcapi.ExecInput) error {
+	ls.Lock()
+	defer ls.Unlock()
+	return ls.stream.Send(in)
+}
+
+func (ls *lockedStream) CloseSend() error {
+	ls.Lock()
+	defer ls.Unlock()
+	return ls.stream.CloseSend()
+}
+
+// terminalSize returns the size of the local terminal or nil if unknown
+func terminalSize(fd int) *rpcapi.WindowSize {
+	cols, rows, err := terminal.GetSize(fd)
+	if err != nil {
+		return nil
+	}
+	return &rpcapi.WindowSize{
+		Rows: uint32(rows),
+		Cols: uint32(cols),
+	}
+}
+
+func doAttach(cmd *cobra.Command, args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("Must include both a node and a command")
+	}
+	fd := int(os.Stdin.Fd())
+	if !terminal.IsTerminal(fd) {
+		return fmt.Errorf("attach requires stdin to be a terminal")
+	}
+	target, err := requestTarget()
+	if err != nil {
+		return err
+	}
+	client, ctx, err := GetGRPCClient(node)
+	if err != nil {
+		return err
+	}
+
+	req := &rpcapi.ExecRequest{
+		CmdName:        args[0],
+		CmdArgs:        args[1:],
+		Tty:            true,
+		WindowSize:     terminalSize(fd),
+		TimeoutSeconds: timeoutSeconds(),
+		Target:         target,
+	}
+	s, err := client.StreamExec(ctx)
+	if err != nil {
+		log.Fatalf("Command failed: %v", err)
+	}
+	stream := &lockedStream{stream: s}
+	if err := stream.Send(&rpcapi.ExecInput{Request: req}); err != nil {
+		log.Fatalf("Command failed: %v", err)
+	}
+
+	// Everything, including ctrl+c, goes to the remote terminal
+	oldState, err := terminal.MakeRaw(fd)
+	if err != nil {
+		return err
+	}
+
+	// Tell the remote terminal whenever the local one changes size
+	winch := make(chan os.Signal, 1)
+	signal.Notify(winch, syscall.SIGWINCH)
+	go func() {
+		for range winch {
+			if size := terminalSize(fd); size != nil {
+				stream.Send(&rpcapi.ExecInput{Resize: size})
+			}
+		}
+	}()
+
+	go copyStdin(stream, os.Stdin)
+
+	status, err := receiveOutput(s)
+	signal.Stop(winch)
+	terminal.Restore(fd, oldState)
+	if err != nil {
+		log.Fatalf("%v\n", err)
+	}
+	exitWithStatus(status)
+	return nil
+}
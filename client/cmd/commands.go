@@ -0,0 +1,183 @@
+// Package client which will connect to a server and run a Go command.
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/spf13/cobra"
+
+	rpcapi "github.com/eparis/admin-rpc/api"
+)
+
+var (
+	commandsOutput string
+)
+
+func init() {
+	commandsCmd := &cobra.Command{
+		Use:   "commands --node=NODE",
+		Short: "List the commands you may run on the remote server",
+		RunE:  doCommands,
+	}
+	commandsCmd.Flags().StringVarP(&commandsOutput, "output", "o", "table", "Output format. One of: table|json")
+	addNodeFlag(commandsCmd)
+	rootCmd.AddCommand(commandsCmd)
+}
+
+// dashed adds the dashes back to a flag name, one character names are short
+// flags
+func dashed(flag string) string {
+	if len(flag) == 1 {
+		return "-" + flag
+	}
+	return "--" + flag
+}
+
+// longFlagString shows a long flag with the patterns its value must match
+func longFlagString(flag *rpcapi.LongFlag) string {
+	if len(flag.Values) == 0 {
+		return "--" + flag.Name
+	}
+	return fmt.Sprintf("--%s=%s", flag.Name, strings.Join(flag.Values, "|"))
+}
+
+// valuesString shows the patterns a value must match and the directories it
+// must be in if it is a path
+func valuesString(values []string, path *rpcapi.PathRule) string {
+	out := strings.Join(values, "|")
+	if path == nil {
+		return out
+	}
+	if out != "" {
+		out += ","
+	}
+	out += "path:" + strings.Join(path.Allow, ":")
+	if len(path.Deny) > 0 {
+		out += ",not:" + strings.Join(path.Deny, ":")
+	}
+	return out
+}
+
+// flagArgString shows a flag which takes a value. Optional values are shown
+// in brackets.
+func flagArgString(flag *rpcapi.FlagArg) string {
+	name := dashed(flag.Name)
+	values := valuesString(flag.Values, flag.Path)
+	switch flag.Arg {
+	case "none":
+		return name
+	case "optional":
+		return fmt.Sprintf("%s[=%s]", name, values)
+	}
+	return fmt.Sprintf("%s=%s", name, values)
+}
+
+// positionalsString shows the positional slots like a man page would, with
+// the patterns for each slot, e.g. `host=^[a-z]+$ [count=^[0-9]+$]`
+func positionalsString(slots []*rpcapi.Positional) string {
+	usage := make([]string, 0, len(slots))
+	for _, slot := range slots {
+		name := slot.Name
+		if slot.Max != 1 {
+			name += "..."
+		}
+		name += "=" + valuesString(slot.Values, slot.Path)
+		if slot.Min == 0 {
+			name = "[" + name + "]"
+		}
+		usage = append(usage, name)
+	}
+	return strings.Join(usage, " ")
+}
+
+// orNone joins the strings or returns a placeholder so table columns line up
+func orNone(in []string) string {
+	if len(in) == 0 {
+		return "<none>"
+	}
+	return strings.Join(in, " ")
+}
+
+// printCommandRow prints the command, along with its aliases, and then each of
+// its subcommands, which run with the same stdin, tty and timeout settings as
+// the command.
+func printCommandRow(w io.Writer, name string, c *rpcapi.CommandInfo, parent *rpcapi.CommandInfo) {
+	long := make([]string, 0, len(c.PermittedLongFlags))
+	for _, flag := range c.PermittedLongFlags {
+		long = append(long, longFlagString(flag))
+	}
+	short := make([]string, 0, len(c.PermittedShortFlags))
+	for _, flag := range c.PermittedShortFlags {
+		short = append(short, "-"+flag)
+	}
+	for _, flag := range c.FlagArgs {
+		if len(flag.Name) == 1 {
+			short = append(short, flagArgString(flag))
+		} else {
+			long = append(long, flagArgString(flag))
+		}
+	}
+	denied := make([]string, 0, len(c.DeniedFlags)+len(c.DeniedFlagValues)+len(c.DeniedNouns))
+	for _, flag := range c.DeniedFlags {
+		denied = append(denied, dashed(flag))
+	}
+	for _, flag := range c.DeniedFlagValues {
+		denied = append(denied, fmt.Sprintf("%s=%s", dashed(flag.Name), strings.Join(flag.Values, "|")))
+	}
+	denied = append(denied, c.DeniedNouns...)
+	nouns := orNone(c.PermittedNouns)
+	if len(c.Positionals) > 0 {
+		nouns = positionalsString(c.Positionals)
+	}
+	timeout := parent.Timeout
+	if timeout == "" {
+		timeout = "<none>"
+	}
+	label := name
+	if c == parent && len(c.Aliases) > 0 {
+		label = fmt.Sprintf("%s (%s)", name, strings.Join(c.Aliases, ", "))
+	}
+	fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%t\t%t\t%s\n", label, orNone(c.RequiredFlags), orNone(short), orNone(long), nouns, orNone(denied), parent.Stdin, parent.Tty, timeout)
+	for _, sub := range c.Subcommands {
+		printCommandRow(w, name+" "+sub.CmdName, sub, parent)
+	}
+}
+
+func printCommandsTable(commands []*rpcapi.CommandInfo) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(w, "NAME\tREQUIRED\tSHORT FLAGS\tLONG FLAGS\tNOUNS\tDENIED\tSTDIN\tTTY\tTIMEOUT\n")
+	for _, c := range commands {
+		printCommandRow(w, c.CmdName, c, c)
+	}
+	return w.Flush()
+}
+
+func doCommands(cmd *cobra.Command, args []string) error {
+	if commandsOutput != "table" && commandsOutput != "json" {
+		return fmt.Errorf("Unknown output format: %s", commandsOutput)
+	}
+	client, ctx, err := GetGRPCClient(node)
+	if err != nil {
+		return err
+	}
+
+	reply, err := client.ListCommands(ctx, &rpcapi.ListCommandsRequest{})
+	if err != nil {
+		return err
+	}
+
+	if commandsOutput == "json" {
+		m := jsonpb.Marshaler{OrigName: true, Indent: "  "}
+		if err := m.Marshal(os.Stdout, reply); err != nil {
+			return err
+		}
+		fmt.Println()
+		return nil
+	}
+	return printCommandsTable(reply.Commands)
+}
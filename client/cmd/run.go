@@ -5,6 +5,10 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/kr/pretty"
 	"github.com/spf13/cobra"
@@ -13,8 +17,15 @@ import (
 )
 
 var (
-	_    = pretty.Print
-	node string
+	_         = pretty.Print
+	node      string
+	sendStdin bool
+	timeout   time.Duration
+	dryRun    bool
+
+	targetPod         string
+	targetContainer   string
+	targetContainerID string
 )
 
 func addNodeFlag(cmd *cobra.Command) {
@@ -23,6 +34,44 @@ func addNodeFlag(cmd *cobra.Command) {
 	cmd.MarkFlagCustom("node", "__client_get_nodes")
 }
 
+func addTimeoutFlag(cmd *cobra.Command) {
+	cmd.Flags().DurationVar(&timeout, "timeout", 0, "Terminate the remote command if it runs longer. The server may enforce a shorter timeout")
+}
+
+func addTargetFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&targetPod, "pod", "", "Run the command in the namespaces of a pod, as NAMESPACE/NAME. The command must permit it")
+	cmd.Flags().StringVarP(&targetContainer, "container", "c", "", "Container in the pod, defaults to the first container")
+	cmd.Flags().StringVar(&targetContainerID, "container-id", "", "Run the command in the namespaces of the container with this ID, instead of --pod")
+}
+
+// requestTarget builds the target of the request from the flags, or nil if
+// the command runs on the host
+func requestTarget() (*rpcapi.Target, error) {
+	if targetPod == "" && targetContainerID == "" {
+		if targetContainer != "" {
+			return nil, fmt.Errorf("--container requires --pod")
+		}
+		return nil, nil
+	}
+	target := &rpcapi.Target{
+		Container:   targetContainer,
+		ContainerID: targetContainerID,
+	}
+	if targetPod != "" {
+		split := strings.SplitN(targetPod, "/", 2)
+		if len(split) != 2 || split[0] == "" || split[1] == "" {
+			return nil, fmt.Errorf("--pod must be NAMESPACE/NAME: %s", targetPod)
+		}
+		target.Namespace, target.Pod = split[0], split[1]
+	}
+	return target, nil
+}
+
+// timeoutSeconds rounds the --timeout up to whole seconds for the request
+func timeoutSeconds() uint32 {
+	return uint32((timeout + time.Second - 1) / time.Second)
+}
+
 func init() {
 	// runCmd represents the base command when called without any subcommands
 	runCmd := &cobra.Command{
@@ -31,10 +80,116 @@ func init() {
 		RunE:  doRun,
 	}
 	runCmd.Flags().SetInterspersed(false)
+	runCmd.Flags().BoolVarP(&sendStdin, "stdin", "i", false, "Send stdin to the command. The command must permit it")
+	runCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show which rules would permit the command without running it")
 	addNodeFlag(runCmd)
+	addTimeoutFlag(runCmd)
+	addTargetFlags(runCmd)
 	rootCmd.AddCommand(runCmd)
 }
 
+// writeOutput copies a chunk of remote output to the matching local output
+func writeOutput(res *rpcapi.ExecReply) {
+	out := os.Stdout
+	if res.Stream == rpcapi.ExecReply_STDERR {
+		out = os.Stderr
+	}
+	out.Write(res.Output)
+	if res.Truncated {
+		fmt.Fprintf(os.Stderr, "\n--- Output truncated, the remote command wrote more than it may ---\n")
+	}
+}
+
+// replyStream is the receiving half of both SendExec and StreamExec
+type replyStream interface {
+	Recv() (*rpcapi.ExecReply, error)
+}
+
+// receiveOutput writes all of the output of the remote command locally and
+// returns how the remote command terminated.
+func receiveOutput(stream replyStream) (*rpcapi.ExecStatus, error) {
+	var status *rpcapi.ExecStatus
+	for {
+		res, err := stream.Recv()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+		writeOutput(res)
+		if res.Status != nil {
+			status = res.Status
+		}
+	}
+	if status == nil {
+		return nil, fmt.Errorf("Stream ended without an exit status")
+	}
+	return status, nil
+}
+
+// exitWithStatus exits with the remote code, so scripts can branch on
+// failures, if the remote command did not succeed.
+func exitWithStatus(status *rpcapi.ExecStatus) {
+	if status.TimedOut {
+		fmt.Fprintf(os.Stderr, "Remote command timed out\n")
+	}
+	if status.Reason != "" {
+		fmt.Fprintf(os.Stderr, "Remote command killed for breaching its limit: %s\n", status.Reason)
+	}
+	if status.Signal != "" {
+		fmt.Fprintf(os.Stderr, "Remote command killed by signal: %s\n", status.Signal)
+	}
+	if status.ExitCode != 0 {
+		os.Exit(int(status.ExitCode))
+	}
+}
+
+// inputStream is the sending half of StreamExec
+type inputStream interface {
+	Send(*rpcapi.ExecInput) error
+	CloseSend() error
+}
+
+// copyStdin sends everything from in to the remote command followed by EOF
+func copyStdin(stream inputStream, in io.Reader) {
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := in.Read(buf)
+		if n > 0 {
+			if err := stream.Send(&rpcapi.ExecInput{Stdin: buf[:n]}); err != nil {
+				return
+			}
+		}
+		if err != nil {
+			// EOF or we can't read anymore, either way there is no more input
+			stream.Send(&rpcapi.ExecInput{Eof: true})
+			stream.CloseSend()
+			return
+		}
+	}
+}
+
+// printValidation shows how every rule for the command judged the request
+func printValidation(reply *rpcapi.ValidateExecReply) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	for i, rule := range reply.Rules {
+		result := "denied"
+		if rule.Allowed {
+			result = "allowed"
+		}
+		fmt.Fprintf(w, "Rule %d: %s\n", i+1, result)
+		for _, check := range rule.Checks {
+			passed := "FAIL"
+			if check.Passed {
+				passed = "ok"
+			}
+			fmt.Fprintf(w, "  %s\t%s\t%s\t%s\n", passed, check.Kind, check.Arg, check.Message)
+		}
+	}
+	return w.Flush()
+}
+
 func doRun(cmd *cobra.Command, args []string) error {
 	if len(args) < 1 {
 		return fmt.Errorf("Must include both a node and a command")
@@ -46,26 +201,53 @@ func doRun(cmd *cobra.Command, args []string) error {
 
 	cmdName := args[0]
 	args = args[1:]
+	target, err := requestTarget()
+	if err != nil {
+		return err
+	}
 
 	// Gets the response of the shell comm and from the server.
 	req := &rpcapi.ExecRequest{
-		CmdName: cmdName,
-		CmdArgs: args,
+		CmdName:        cmdName,
+		CmdArgs:        args,
+		TimeoutSeconds: timeoutSeconds(),
+		Target:         target,
 	}
-	stream, err := client.SendExec(ctx, req)
-	if err != nil {
-		log.Fatalf("Command failed: %v", err)
+	if dryRun {
+		reply, err := client.ValidateExec(ctx, req)
+		if err != nil {
+			return err
+		}
+		if err := printValidation(reply); err != nil {
+			return err
+		}
+		if !reply.Allowed {
+			os.Exit(1)
+		}
+		return nil
 	}
-
-	for {
-		res, err := stream.Recv()
+	var stream replyStream
+	if sendStdin {
+		s, err := client.StreamExec(ctx)
 		if err != nil {
-			if err == io.EOF {
-				break
-			}
-			log.Fatalf("%v\n", err)
+			log.Fatalf("Command failed: %v", err)
 		}
-		fmt.Printf("%s", res.Output)
+		if err := s.Send(&rpcapi.ExecInput{Request: req}); err != nil {
+			log.Fatalf("Command failed: %v", err)
+		}
+		go copyStdin(s, os.Stdin)
+		stream = s
+	} else {
+		stream, err = client.SendExec(ctx, req)
+		if err != nil {
+			log.Fatalf("Command failed: %v", err)
+		}
+	}
+
+	status, err := receiveOutput(stream)
+	if err != nil {
+		log.Fatalf("%v\n", err)
 	}
+	exitWithStatus(status)
 	return nil
 }
@@ -85,7 +85,20 @@ func doShell(cmd *cobra.Command, args []string) error {
 				fmt.Printf("Exec failed: %v\n", err)
 				break
 			}
-			fmt.Printf("%s", res.Output)
+			writeOutput(res)
+			if status := res.Status; status != nil && status.ExitCode != 0 {
+				if status.TimedOut {
+					fmt.Printf("Timed out\n")
+				}
+				if status.Reason != "" {
+					fmt.Printf("Breached limit: %s\n", status.Reason)
+				}
+				if status.Signal != "" {
+					fmt.Printf("Killed by signal: %s\n", status.Signal)
+				} else {
+					fmt.Printf("Exit status: %d\n", status.ExitCode)
+				}
+			}
 		}
 	}
 	return nil
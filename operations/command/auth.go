@@ -0,0 +1,253 @@
+package command
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"golang.org/x/net/context"
+	authzv1 "k8s.io/api/authorization/v1"
+)
+
+// authVars are what the name and nonResourceURL of an ExecAuth may refer
+// to, e.g. `{{.NodeName}}` or `{{index .Nouns 0}}`
+type authVars struct {
+	// NodeName is the node the server runs on, empty if it is not known
+	NodeName string
+	// NodeLabels are the labels of the node, nil if it is not known
+	NodeLabels map[string]string
+	// Nouns are the non-flag arguments of the request, after any subcommands
+	Nouns []string
+	// Namespace and Pod are the pod the request targets, empty without a
+	// target
+	Namespace string
+	Pod       string
+}
+
+// authVars returns what the auth of a request for the target may refer to
+func (s *sndCmd) authVars(nouns []string, t *target) *authVars {
+	vars := &authVars{
+		NodeName:   s.nodeName,
+		NodeLabels: s.nodeLabels,
+		Nouns:      nouns,
+	}
+	if t != nil {
+		vars.Namespace = t.namespace
+		vars.Pod = t.pod
+	}
+	return vars
+}
+
+// UnmarshalYAML also accepts a list of clauses, which must all pass
+func (auth *ExecAuth) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var raw interface{}
+	if err := unmarshal(&raw); err != nil {
+		return err
+	}
+	if _, ok := raw.([]interface{}); ok {
+		var clauses []*ExecAuth
+		if err := unmarshal(&clauses); err != nil {
+			return err
+		}
+		*auth = ExecAuth{AllOf: clauses}
+		return nil
+	}
+	// plain has no UnmarshalYAML, so this does not recurse
+	type plain ExecAuth
+	return unmarshal((*plain)(auth))
+}
+
+// build checks the auth and parses the templates of its name or
+// nonResourceURL
+func (auth *ExecAuth) build() error {
+	if len(auth.AllOf) > 0 || len(auth.AnyOf) > 0 {
+		return auth.buildClauses()
+	}
+	if auth.Verb == "" {
+		return fmt.Errorf("verb must be set")
+	}
+	if auth.NonResourceURL != "" {
+		if !strings.HasPrefix(auth.NonResourceURL, "/") {
+			return fmt.Errorf("nonResourceURL must start with /: %s", auth.NonResourceURL)
+		}
+		if auth.Namespace != "" || auth.Group != "" || auth.Resource != "" || auth.Subresource != "" || auth.Version != "" || auth.Name != "" {
+			return fmt.Errorf("nonResourceURL can not be set along with a resource")
+		}
+		url, err := template.New("nonResourceURL").Option("missingkey=error").Parse(auth.NonResourceURL)
+		if err != nil {
+			return fmt.Errorf("invalid nonResourceURL: %v", err)
+		}
+		auth.nonResourceURL = url
+		return nil
+	}
+	if auth.Resource == "" {
+		return fmt.Errorf("resource or nonResourceURL must be set")
+	}
+	if auth.Name != "" {
+		name, err := template.New("name").Option("missingkey=error").Parse(auth.Name)
+		if err != nil {
+			return fmt.Errorf("invalid name: %v", err)
+		}
+		auth.name = name
+	}
+	return nil
+}
+
+// buildClauses builds the clauses of allOf or anyOf
+func (auth *ExecAuth) buildClauses() error {
+	if len(auth.AllOf) > 0 && len(auth.AnyOf) > 0 {
+		return fmt.Errorf("allOf and anyOf can not both be set, nest one in the other")
+	}
+	if auth.Namespace != "" || auth.Verb != "" || auth.Group != "" || auth.Resource != "" || auth.Subresource != "" || auth.Version != "" || auth.Name != "" || auth.NonResourceURL != "" {
+		return fmt.Errorf("allOf and anyOf can not be set along with a resource or nonResourceURL")
+	}
+	kind, clauses := "allOf", auth.AllOf
+	if len(auth.AnyOf) > 0 {
+		kind, clauses = "anyOf", auth.AnyOf
+	}
+	for i, clause := range clauses {
+		if clause == nil {
+			return fmt.Errorf("%s[%d]: empty clause", kind, i)
+		}
+		if err := clause.build(); err != nil {
+			return fmt.Errorf("%s[%d]: %v", kind, i, err)
+		}
+	}
+	return nil
+}
+
+// buildAuth builds the auth of the command and of all of its subcommands
+func (exec *Exec) buildAuth() error {
+	if err := exec.Auth.build(); err != nil {
+		return fmt.Errorf("auth for %s: %v", exec.CmdName, err)
+	}
+	if err := exec.ArgRule.buildAuth(); err != nil {
+		return fmt.Errorf("auth for %s: %v", exec.CmdName, err)
+	}
+	return nil
+}
+
+func (rule *ArgRule) buildAuth() error {
+	for name, sub := range rule.Subcommands {
+		if sub.Auth != nil {
+			if err := sub.Auth.build(); err != nil {
+				return fmt.Errorf("subcommand %s: %v", name, err)
+			}
+		}
+		if err := sub.ArgRule.buildAuth(); err != nil {
+			return fmt.Errorf("subcommand %s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// render executes the template of an auth with vars. It is an error for the
+// result to be empty.
+func render(tmpl *template.Template, vars *authVars) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, vars); err != nil {
+		return "", err
+	}
+	if buf.Len() == 0 {
+		return "", fmt.Errorf("it is empty")
+	}
+	return buf.String(), nil
+}
+
+// attributes returns what the SubjectAccessReview asks about, the resource
+// or, if attrs is nil, the nonResourceURL, with the templates rendered from
+// vars
+func (auth *ExecAuth) attributes(vars *authVars) (*authzv1.ResourceAttributes, *authzv1.NonResourceAttributes, error) {
+	if auth.nonResourceURL != nil {
+		path, err := render(auth.nonResourceURL, vars)
+		if err != nil {
+			return nil, nil, fmt.Errorf("Unable to build the nonResourceURL %q to authorize: %v", auth.NonResourceURL, err)
+		}
+		return nil, &authzv1.NonResourceAttributes{
+			Path: path,
+			Verb: auth.Verb,
+		}, nil
+	}
+	attrs := &authzv1.ResourceAttributes{
+		Namespace:   auth.Namespace,
+		Verb:        auth.Verb,
+		Group:       auth.Group,
+		Version:     auth.Version,
+		Resource:    auth.Resource,
+		Subresource: auth.Subresource,
+	}
+	if auth.name != nil {
+		// An empty name would ask for every resource of the kind
+		name, err := render(auth.name, vars)
+		if err != nil {
+			return nil, nil, fmt.Errorf("Unable to build the name %q of %s to authorize: %v", auth.Name, resourceString(attrs), err)
+		}
+		attrs.Name = name
+	}
+	return attrs, nil, nil
+}
+
+// describe shows what the requestor must be allowed to do, for ValidateExec
+func (auth *ExecAuth) describe(vars *authVars) string {
+	if len(auth.AllOf) > 0 || len(auth.AnyOf) > 0 {
+		kind, clauses := "allOf", auth.AllOf
+		if len(auth.AnyOf) > 0 {
+			kind, clauses = "anyOf", auth.AnyOf
+		}
+		descs := make([]string, 0, len(clauses))
+		for _, clause := range clauses {
+			descs = append(descs, clause.describe(vars))
+		}
+		return fmt.Sprintf("%s(%s)", kind, strings.Join(descs, "; "))
+	}
+	attrs, nonResource, err := auth.attributes(vars)
+	if err != nil && auth.NonResourceURL != "" {
+		return fmt.Sprintf("%s %s", auth.Verb, auth.NonResourceURL)
+	}
+	if err != nil {
+		attrs = &authzv1.ResourceAttributes{
+			Namespace:   auth.Namespace,
+			Verb:        auth.Verb,
+			Group:       auth.Group,
+			Resource:    auth.Resource,
+			Subresource: auth.Subresource,
+			Name:        auth.Name,
+		}
+	}
+	if attrs == nil {
+		return fmt.Sprintf("%s %s", nonResource.Verb, nonResource.Path)
+	}
+	if attrs.Namespace == "" {
+		return fmt.Sprintf("%s %s", attrs.Verb, resourceString(attrs))
+	}
+	return fmt.Sprintf("%s %s in namespace %s", attrs.Verb, resourceString(attrs), attrs.Namespace)
+}
+
+// resourceString names the resource the way kubectl does, e.g.
+// `deployments.apps/scale name`
+func resourceString(attrs *authzv1.ResourceAttributes) string {
+	resource := attrs.Resource
+	if attrs.Group != "" {
+		resource += "." + attrs.Group
+	}
+	if attrs.Subresource != "" {
+		resource += "/" + attrs.Subresource
+	}
+	if attrs.Name != "" {
+		resource += " " + attrs.Name
+	}
+	return resource
+}
+
+// nodeAuthz checks, if the config sets a nodeAuth, that the requestor may run
+// commands on this node at all
+func (s *sndCmd) nodeAuthz(ctx context.Context, t *target) error {
+	if s.nodeAuth == nil {
+		return nil
+	}
+	if err := s.nodeAuth.authz(ctx, s.authVars(nil, t)); err != nil {
+		return fmt.Errorf("Not allowed to run commands on node %q: %v", s.nodeName, err)
+	}
+	return nil
+}
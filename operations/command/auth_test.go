@@ -0,0 +1,223 @@
+package command
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/context"
+	yaml "gopkg.in/yaml.v2"
+	authzv1 "k8s.io/api/authorization/v1"
+)
+
+// reviewString describes what a SubjectAccessReview asks about, e.g.
+// `get pods web in namespace default` or `get /healthz`
+func reviewString(sar *authzv1.SubjectAccessReview) string {
+	if attrs := sar.Spec.ResourceAttributes; attrs != nil {
+		if attrs.Namespace == "" {
+			return fmt.Sprintf("%s %s", attrs.Verb, resourceString(attrs))
+		}
+		return fmt.Sprintf("%s %s in namespace %s", attrs.Verb, resourceString(attrs), attrs.Namespace)
+	}
+	return fmt.Sprintf("%s %s", sar.Spec.NonResourceAttributes.Verb, sar.Spec.NonResourceAttributes.Path)
+}
+
+// stubAccessReview answers SubjectAccessReviews instead of the API server,
+// allowing only what is listed in allowed. It returns the reviews asked for
+// and a func which puts the API server back.
+func stubAccessReview(allowed ...string) (*[]string, func()) {
+	asked := []string{}
+	saved := createAccessReview
+	createAccessReview = func(ctx context.Context, sar *authzv1.SubjectAccessReview) (*authzv1.SubjectAccessReview, error) {
+		review := reviewString(sar)
+		asked = append(asked, review)
+		for _, allow := range allowed {
+			if review == allow {
+				sar.Status.Allowed = true
+			}
+		}
+		return sar, nil
+	}
+	return &asked, func() { createAccessReview = saved }
+}
+
+// newTestAuth loads an auth from its yaml the way a command's is
+func newTestAuth(t *testing.T, config string) *ExecAuth {
+	auth := &ExecAuth{}
+	if err := yaml.Unmarshal([]byte(config), auth); err != nil {
+		t.Fatalf("Unable to parse auth: %v", err)
+	}
+	if err := auth.build(); err != nil {
+		t.Fatalf("Unable to build auth %q: %v", config, err)
+	}
+	return auth
+}
+
+func TestAuthAttributes(t *testing.T) {
+	vars := &authVars{
+		NodeName:   "node1",
+		NodeLabels: map[string]string{"zone": "a"},
+		Nouns:      []string{"eth0", "tcp"},
+		Namespace:  "default",
+		Pod:        "web",
+	}
+	tests := []struct {
+		auth string
+		vars *authVars
+		// review is what is asked about, err a part of the error expected
+		review string
+		err    string
+	}{
+		{"{verb: get, resource: pods}", vars, "get pods", ""},
+		{"{verb: get, resource: pods, namespace: kube-system}", vars, "get pods in namespace kube-system", ""},
+		{"{verb: get, group: apps, resource: deployments, subresource: scale}", vars, "get deployments.apps/scale", ""},
+		{"{verb: get, resource: nodes, name: '{{.NodeName}}'}", vars, "get nodes node1", ""},
+		{"{verb: get, resource: nodes, name: 'zone-{{index .NodeLabels \"zone\"}}'}", vars, "get nodes zone-a", ""},
+		{"{verb: get, resource: pods, name: '{{.Pod}}', namespace: default}", vars, "get pods web in namespace default", ""},
+		{"{verb: get, resource: interfaces, name: '{{index .Nouns 0}}'}", vars, "get interfaces eth0", ""},
+		{"{verb: get, nonResourceURL: '/tcpdump/{{index .Nouns 1}}'}", vars, "get /tcpdump/tcp", ""},
+
+		// an empty name would ask about every resource of the kind
+		{"{verb: get, resource: pods, name: '{{.Pod}}'}", &authVars{}, "", "Unable to build the name \"{{.Pod}}\" of pods to authorize: it is empty"},
+		{"{verb: get, resource: nodes, name: '{{index .NodeLabels \"rack\"}}'}", vars, "", "it is empty"},
+
+		// index past the nouns given
+		{"{verb: get, resource: interfaces, name: '{{index .Nouns 0}}'}", &authVars{}, "", "error calling index"},
+		{"{verb: get, nonResourceURL: '/tcpdump/{{index .Nouns 2}}'}", vars, "", "error calling index"},
+		{"{verb: get, resource: pods, name: '{{.Nope}}'}", vars, "", "can't evaluate field Nope"},
+	}
+	for _, test := range tests {
+		auth := newTestAuth(t, test.auth)
+		attrs, nonResource, err := auth.attributes(test.vars)
+		switch {
+		case test.err == "" && err != nil:
+			t.Errorf("%s: unexpected error: %v", test.auth, err)
+		case test.err != "" && err == nil:
+			t.Errorf("%s: expected error %q, got none", test.auth, test.err)
+		case test.err != "" && !strings.Contains(err.Error(), test.err):
+			t.Errorf("%s: expected error %q, got: %v", test.auth, test.err, err)
+		case err == nil:
+			sar := &authzv1.SubjectAccessReview{}
+			sar.Spec.ResourceAttributes = attrs
+			sar.Spec.NonResourceAttributes = nonResource
+			if review := reviewString(sar); review != test.review {
+				t.Errorf("%s: expected %q, got %q", test.auth, test.review, review)
+			}
+		}
+	}
+}
+
+func TestAuthBuild(t *testing.T) {
+	tests := []struct {
+		auth string
+		err  string
+	}{
+		{"{verb: get, resource: pods}", ""},
+		{"{verb: get, nonResourceURL: /healthz}", ""},
+		{"{resource: pods}", "verb must be set"},
+		{"{verb: get}", "resource or nonResourceURL must be set"},
+		{"{verb: get, nonResourceURL: healthz}", "nonResourceURL must start with /"},
+		{"{verb: get, nonResourceURL: /healthz, resource: pods}", "nonResourceURL can not be set along with a resource"},
+		{"{verb: get, resource: pods, name: '{{.Pod'}", "invalid name"},
+		{"{verb: get, nonResourceURL: '/{{'}", "invalid nonResourceURL"},
+	}
+	for _, test := range tests {
+		auth := &ExecAuth{}
+		if err := yaml.Unmarshal([]byte(test.auth), auth); err != nil {
+			t.Fatalf("Unable to parse auth: %v", err)
+		}
+		err := auth.build()
+		switch {
+		case test.err == "" && err != nil:
+			t.Errorf("%s: unexpected error: %v", test.auth, err)
+		case test.err != "" && err == nil:
+			t.Errorf("%s: expected error %q, got none", test.auth, test.err)
+		case test.err != "" && !strings.Contains(err.Error(), test.err):
+			t.Errorf("%s: expected error %q, got: %v", test.auth, test.err, err)
+		}
+	}
+}
+
+func TestAuthz(t *testing.T) {
+	asked, restore := stubAccessReview("get pods web in namespace default", "get /tcpdump/eth0")
+	defer restore()
+	ctx := userContext(context.Background(), "alice")
+	vars := &authVars{Nouns: []string{"eth0"}, Namespace: "default", Pod: "web"}
+	tests := []struct {
+		auth string
+		// asked are the reviews expected, err a part of the error expected
+		asked []string
+		err   string
+	}{
+		{"{verb: get, resource: pods, namespace: default, name: '{{.Pod}}'}", []string{"get pods web in namespace default"}, ""},
+		{"{verb: get, nonResourceURL: '/tcpdump/{{index .Nouns 0}}'}", []string{"get /tcpdump/eth0"}, ""},
+		{"{verb: delete, resource: pods, namespace: default, name: web}", []string{"delete pods web in namespace default"}, `user: "alice" is not allowed to "delete" "pods web" in the "default" namespace`},
+		{"{verb: get, resource: nodes}", []string{"get nodes"}, `user: "alice" is not allowed to "get" "nodes"`},
+		{"{verb: get, nonResourceURL: /healthz}", []string{"get /healthz"}, `user: "alice" is not allowed to "get" "/healthz"`},
+		// nothing is asked if the name can not be built
+		{"{verb: get, resource: pods, name: '{{index .Nouns 1}}'}", []string{}, "Unable to build the name"},
+	}
+	for _, test := range tests {
+		*asked = []string{}
+		err := newTestAuth(t, test.auth).authz(ctx, vars)
+		switch {
+		case test.err == "" && err != nil:
+			t.Errorf("%s: unexpected error: %v", test.auth, err)
+		case test.err != "" && err == nil:
+			t.Errorf("%s: expected error %q, got none", test.auth, test.err)
+		case test.err != "" && !strings.Contains(err.Error(), test.err):
+			t.Errorf("%s: expected error %q, got: %v", test.auth, test.err, err)
+		}
+		if strings.Join(*asked, ", ") != strings.Join(test.asked, ", ") {
+			t.Errorf("%s: expected to ask %q, asked %q", test.auth, test.asked, *asked)
+		}
+	}
+}
+
+func TestAuthzClauses(t *testing.T) {
+	asked, restore := stubAccessReview("get pods", "get nodes", "get /healthz")
+	defer restore()
+	ctx := userContext(context.Background(), "alice")
+	vars := &authVars{}
+	tests := []struct {
+		auth string
+		// asked are the reviews expected, err the whole error expected
+		asked []string
+		err   string
+	}{
+		// a list is the same as allOf
+		{"[{verb: get, resource: pods}, {verb: get, resource: nodes}]", []string{"get pods", "get nodes"}, ""},
+		{"{allOf: [{verb: get, resource: pods}, {verb: get, nonResourceURL: /healthz}]}", []string{"get pods", "get /healthz"}, ""},
+		// allOf stops at, and names, the first clause which failed
+		{"{allOf: [{verb: get, resource: pods}, {verb: list, resource: pods}, {verb: get, resource: nodes}]}", []string{"get pods", "list pods"},
+			`allOf[1]: user: "alice" is not allowed to "list" "pods". Refusing`},
+		// anyOf stops at the first clause which passed
+		{"{anyOf: [{verb: get, resource: pods}, {verb: list, resource: pods}]}", []string{"get pods"}, ""},
+		{"{anyOf: [{verb: list, resource: pods}, {verb: get, resource: nodes}]}", []string{"list pods", "get nodes"}, ""},
+		// and names every clause when none did
+		{"{anyOf: [{verb: list, resource: pods}, {verb: list, resource: nodes}]}", []string{"list pods", "list nodes"},
+			`anyOf[0]: user: "alice" is not allowed to "list" "pods". Refusing; anyOf[1]: user: "alice" is not allowed to "list" "nodes". Refusing`},
+		// nested clauses name the whole path
+		{"{allOf: [{verb: get, resource: pods}, {anyOf: [{verb: list, resource: pods}, {allOf: [{verb: get, resource: nodes}, {verb: list, resource: nodes}]}]}]}",
+			[]string{"get pods", "list pods", "get nodes", "list nodes"},
+			`allOf[1]: anyOf[0]: user: "alice" is not allowed to "list" "pods". Refusing; anyOf[1]: allOf[1]: user: "alice" is not allowed to "list" "nodes". Refusing`},
+		{"{anyOf: [{verb: get, resource: pods, name: '{{index .Nouns 0}}'}, {verb: get, resource: nodes}]}", []string{"get nodes"}, ""},
+		{"{allOf: [{verb: get, resource: nodes}, {verb: get, resource: pods, name: '{{.Pod}}'}]}", []string{"get nodes"},
+			`allOf[1]: Unable to build the name "{{.Pod}}" of pods to authorize: it is empty`},
+	}
+	for _, test := range tests {
+		*asked = []string{}
+		err := newTestAuth(t, test.auth).authz(ctx, vars)
+		switch {
+		case test.err == "" && err != nil:
+			t.Errorf("%s: unexpected error: %v", test.auth, err)
+		case test.err != "" && err == nil:
+			t.Errorf("%s: expected error %q, got none", test.auth, test.err)
+		case test.err != "" && err.Error() != test.err:
+			t.Errorf("%s: expected error %q, got: %v", test.auth, test.err, err)
+		}
+		if strings.Join(*asked, ", ") != strings.Join(test.asked, ", ") {
+			t.Errorf("%s: expected to ask %q, asked %q", test.auth, test.asked, *asked)
+		}
+	}
+}
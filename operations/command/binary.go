@@ -0,0 +1,76 @@
+package command
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+func (exec *Exec) buildBinary() error {
+	if exec.BinaryPath != "" && (!filepath.IsAbs(exec.BinaryPath) || filepath.Clean(exec.BinaryPath) != exec.BinaryPath) {
+		return fmt.Errorf("binaryPath for %s must be absolute and clean: %s", exec.CmdName, exec.BinaryPath)
+	}
+	if exec.SHA256 == "" {
+		return nil
+	}
+	if exec.BinaryPath == "" {
+		return fmt.Errorf("sha256 for %s requires binaryPath", exec.CmdName)
+	}
+	exec.SHA256 = strings.ToLower(exec.SHA256)
+	if sum, err := hex.DecodeString(exec.SHA256); err != nil || len(sum) != sha256.Size {
+		return fmt.Errorf("sha256 for %s must be %d hex digits: %s", exec.CmdName, 2*sha256.Size, exec.SHA256)
+	}
+	return nil
+}
+
+// checkBinary hashes the binary in the mount namespace the command runs in
+// and compares it to SHA256. The binary could still be replaced between the
+// check and the exec, the check is against packages being updated or tampered
+// with while the configuration stays the same.
+func (exec *Exec) checkBinary(t *target) error {
+	if exec.SHA256 == "" {
+		return nil
+	}
+	root := exec.root(t)
+	resolved, err := resolvePath(root, exec.BinaryPath)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(filepath.Join(root, resolved))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return err
+	}
+	if sum := hex.EncodeToString(hash.Sum(nil)); sum != exec.SHA256 {
+		return grpc.Errorf(codes.FailedPrecondition, "Binary for %s does not match its sha256: %s is %s", exec.CmdName, exec.BinaryPath, sum)
+	}
+	return nil
+}
+
+// commandLine returns the binary to run and its arguments. Only cmdArgs came
+// from the request, the rest is from the configuration.
+func (exec *Exec) commandLine(cmdArgs []string, t *target) (string, []string, error) {
+	if err := exec.checkBinary(t); err != nil {
+		return "", nil, err
+	}
+	binary := exec.CmdName
+	if exec.BinaryPath != "" {
+		binary = exec.BinaryPath
+	}
+	args := make([]string, 0, len(exec.PrependArgs)+len(cmdArgs)+len(exec.AppendArgs))
+	args = append(args, exec.PrependArgs...)
+	args = append(args, cmdArgs...)
+	args = append(args, exec.AppendArgs...)
+	return binary, args, nil
+}
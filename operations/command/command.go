@@ -1,16 +1,25 @@
 package command
 
 import (
+	"crypto/sha256"
+	"encoding/json"
 	"fmt"
 	"path/filepath"
 	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
 
 	//"github.com/kr/pretty"
 	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	//"google.golang.org/grpc/metadata"
 	authzv1 "k8s.io/api/authorization/v1"
 
 	rpcapi "github.com/eparis/admin-rpc/api"
+	"github.com/eparis/admin-rpc/operations/nsenter"
 	"github.com/eparis/admin-rpc/operations/util"
 )
 
@@ -28,21 +37,163 @@ func (a argRegex) valid(val string) bool {
 	return false
 }
 
+// match returns the first pattern which matches val, or nil
+func (a argRegex) match(val string) *regexp.Regexp {
+	for _, v := range a {
+		if v.MatchString(val) {
+			return v
+		}
+	}
+	return nil
+}
+
+// ExecAuth is what the requestor must be allowed to do, checked with a
+// SubjectAccessReview, to run the command. It names either a resource or a
+// nonResourceURL, or is made of other ExecAuths with allOf or anyOf.
 type ExecAuth struct {
-	Namespace string `json:"namespace" yaml:"namespace"`
-	Verb      string `json:"verb" yaml:"verb"`
-	Resource  string `json:"resource" yaml:"resource"`
-	Version   string `json:"version" yaml:"version"`
+	Namespace   string `json:"namespace" yaml:"namespace"`
+	Verb        string `json:"verb" yaml:"verb"`
+	Group       string `json:"group,omitempty" yaml:"group,omitempty"`
+	Resource    string `json:"resource" yaml:"resource"`
+	Subresource string `json:"subresource,omitempty" yaml:"subresource,omitempty"`
+	Version     string `json:"version" yaml:"version"`
+	// Name is a text/template of the name of the resource, see authVars
+	// for what it may refer to
+	Name string `json:"name,omitempty" yaml:"name,omitempty"`
+	name *template.Template
+	// NonResourceURL is a text/template of the path asked about instead of
+	// a resource, e.g. /admin-rpc/tcpdump
+	NonResourceURL string `json:"nonResourceURL,omitempty" yaml:"nonResourceURL,omitempty"`
+	nonResourceURL *template.Template
+	// AllOf are clauses which must all pass, AnyOf clauses of which at least
+	// one must pass. An auth given as a list is the same as allOf.
+	AllOf []*ExecAuth `json:"allOf,omitempty" yaml:"allOf,omitempty"`
+	AnyOf []*ExecAuth `json:"anyOf,omitempty" yaml:"anyOf,omitempty"`
 }
-type Exec struct {
-	Auth           ExecAuth            `json:"auth" yaml:"auth"`
-	CmdName        string              `json:"cmdName" yaml:"cmdName"`
+
+// How a flag in flagArgs takes a value
+const (
+	FlagArgNone     = "none"
+	FlagArgOptional = "optional"
+	FlagArgRequired = "required"
+)
+
+// FlagArg describes the value taken by a short or long flag
+type FlagArg struct {
+	// Arg is none, optional or required. A required value may be attached
+	// (`-c5`, `--count=5`) or the next argument (`-c 5`, `--count 5`). An
+	// optional value must be attached. Defaults to required.
+	Arg string `json:"arg,omitempty" yaml:"arg,omitempty"`
+	// Values are the patterns the value must match
+	Values []string `json:"values,omitempty" yaml:"values,omitempty"`
+	values argRegex
+	// Path, if set, checks the value as a path
+	Path *PathRule `json:"path,omitempty" yaml:"path,omitempty"`
+}
+
+// ArgRule is the part of the policy which checks the arguments of a command
+// or of one of its subcommands.
+type ArgRule struct {
 	Required       []string            `json:"requiredFlags,omitempty" yaml:"requiredFlags,omitempty"`
 	PermittedShort []string            `json:"permittedShortFlags,omitempty" yaml:"permittedShortFlags,omitempty"`
 	PermittedLong  map[string][]string `json:"permittedLongFlags,omitempty" yaml:"permittedLongFlags,omitempty"`
 	permittedLong  map[string]argRegex
 	PermittedNoun  []string `json:"permittedNouns,omitempty" yaml:"permittedNouns,omitempty"`
 	permittedNoun  argRegex
+	// FlagArgs are flags which take a value. Flags named with one character
+	// are short flags, all others are long flags.
+	FlagArgs map[string]*FlagArg `json:"flagArgs,omitempty" yaml:"flagArgs,omitempty"`
+	// DeniedFlags, DeniedNouns and DeniedFlagValues are checked before, and
+	// override, everything which is permitted
+	DeniedFlags      []string `json:"deniedFlags,omitempty" yaml:"deniedFlags,omitempty"`
+	DeniedNouns      []string `json:"deniedNouns,omitempty" yaml:"deniedNouns,omitempty"`
+	deniedNoun       argRegex
+	DeniedFlagValues map[string][]string `json:"deniedFlagValues,omitempty" yaml:"deniedFlagValues,omitempty"`
+	deniedFlagValue  map[string]argRegex
+	// Positionals, if set, are checked against the non-flag arguments
+	// instead of PermittedNoun
+	Positionals []*Positional `json:"positionals,omitempty" yaml:"positionals,omitempty"`
+	// Subcommands are the verbs of a multi-verb tool, e.g. `docker ps`. If
+	// the first noun names a subcommand every later argument is checked
+	// against the subcommand instead.
+	Subcommands map[string]*Subcommand `json:"subcommands,omitempty" yaml:"subcommands,omitempty"`
+}
+
+// Subcommand is one verb of a multi-verb tool
+type Subcommand struct {
+	ArgRule `json:",inline" yaml:",inline"`
+	// Auth, if set, is checked instead of the auth of the parent command
+	Auth *ExecAuth `json:"auth,omitempty" yaml:"auth,omitempty"`
+}
+
+type Exec struct {
+	Auth    ExecAuth `json:"auth" yaml:"auth"`
+	CmdName string   `json:"cmdName" yaml:"cmdName"`
+	// Enable defaults to true. A command which is not enabled is not loaded.
+	Enable *bool `json:"enable,omitempty" yaml:"enable,omitempty"`
+	// When, if set, only loads the command on nodes which meet at least one
+	// of the conditions
+	When []*Condition `json:"when,omitempty" yaml:"when,omitempty"`
+	// Aliases are other names the command may be run as
+	Aliases []string `json:"aliases,omitempty" yaml:"aliases,omitempty"`
+	// BinaryPath is the absolute path of the binary to run in the host mount
+	// namespace. Defaults to looking up CmdName in the PATH of the server.
+	BinaryPath string `json:"binaryPath,omitempty" yaml:"binaryPath,omitempty"`
+	// SHA256, if set, must match the binary before it is run
+	SHA256 string `json:"sha256,omitempty" yaml:"sha256,omitempty"`
+	// PrependArgs and AppendArgs are added before and after the arguments
+	// of the request. They are not checked against the rules.
+	PrependArgs []string `json:"prependArgs,omitempty" yaml:"prependArgs,omitempty"`
+	AppendArgs  []string `json:"appendArgs,omitempty" yaml:"appendArgs,omitempty"`
+	// Namespaces maps each of mount, uts, ipc, net, pid, user and cgroup to
+	// host, self, the namespace of the server, or target, the container
+	// named by the request. user and cgroup default to self, the others to
+	// host.
+	Namespaces       map[string]string `json:"namespaces,omitempty" yaml:"namespaces,omitempty"`
+	namespaces       util.Namespaces
+	hostNamespaces   []string
+	targetNamespaces []string
+	ArgRule          `json:",inline" yaml:",inline"`
+	// MaxArgs limits how many arguments, flags or not, may be passed
+	MaxArgs int `json:"maxArgs,omitempty" yaml:"maxArgs,omitempty"`
+	// MaxArgLength limits the length of every argument
+	MaxArgLength int `json:"maxArgLength,omitempty" yaml:"maxArgLength,omitempty"`
+	// Stdin must be set for the command to be run with StreamExec
+	Stdin bool `json:"stdin,omitempty" yaml:"stdin,omitempty"`
+	// TTY must be set for the command to be run on a pseudo terminal
+	TTY bool `json:"tty,omitempty" yaml:"tty,omitempty"`
+	// Timeout is the longest the command may run, e.g. "30s". Clients may
+	// ask for a shorter timeout but never a longer one.
+	Timeout string `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+	timeout time.Duration
+	// GracePeriod is how long the command has to exit after SIGTERM before
+	// it is sent SIGKILL.
+	GracePeriod string `json:"gracePeriod,omitempty" yaml:"gracePeriod,omitempty"`
+	gracePeriod time.Duration
+	// Limits, if set, are the resources the command may use
+	Limits *Limits `json:"limits,omitempty" yaml:"limits,omitempty"`
+	limits *util.Limits
+	// MaxOutputBytes is the most output, stdout and stderr together, the
+	// client is sent. The command is terminated once it writes more.
+	MaxOutputBytes int64 `json:"maxOutputBytes,omitempty" yaml:"maxOutputBytes,omitempty"`
+	// OutputBytesPerSecond limits how fast output is sent to the client
+	OutputBytesPerSecond int64 `json:"outputBytesPerSecond,omitempty" yaml:"outputBytesPerSecond,omitempty"`
+	// Concurrency, if set, limits how many of the command run at once
+	Concurrency *Concurrency `json:"concurrency,omitempty" yaml:"concurrency,omitempty"`
+	limiter     *limiter
+	// RunAs, if set, is who the command runs as instead of root. It has no
+	// supplementary groups unless they are listed.
+	RunAs *RunAs `json:"runAs,omitempty" yaml:"runAs,omitempty"`
+	// Capabilities, if set, are the only capabilities the command has, e.g.
+	// NET_RAW. An empty list drops all of them.
+	Capabilities []string `json:"capabilities,omitempty" yaml:"capabilities,omitempty"`
+	// NoNewPrivs stops the command gaining privileges through setuid
+	// binaries or file capabilities
+	NoNewPrivs bool `json:"noNewPrivs,omitempty" yaml:"noNewPrivs,omitempty"`
+	// SeccompProfile, if set, is the path of a compiled seccomp filter the
+	// command runs under. It implies NoNewPrivs.
+	SeccompProfile string `json:"seccompProfile,omitempty" yaml:"seccompProfile,omitempty"`
+	privileges     *nsenter.Privileges
 }
 
 func stringsToRe(in []string) (argRegex, error) {
@@ -57,27 +208,250 @@ func stringsToRe(in []string) (argRegex, error) {
 	return regs, nil
 }
 
-func (exec *Exec) buildRegex() error {
-	exec.permittedLong = map[string]argRegex{}
-	for flag, vals := range exec.PermittedLong {
+func (rule *ArgRule) buildRegex() error {
+	rule.permittedLong = map[string]argRegex{}
+	for flag, vals := range rule.PermittedLong {
+		regs, err := stringsToRe(vals)
+		if err != nil {
+			return err
+		}
+		rule.permittedLong[flag] = regs
+	}
+	regs, err := stringsToRe(rule.PermittedNoun)
+	if err != nil {
+		return err
+	}
+	rule.permittedNoun = regs
+	if rule.deniedNoun, err = stringsToRe(rule.DeniedNouns); err != nil {
+		return err
+	}
+	rule.deniedFlagValue = map[string]argRegex{}
+	for flag, vals := range rule.DeniedFlagValues {
 		regs, err := stringsToRe(vals)
 		if err != nil {
 			return err
 		}
-		exec.permittedLong[flag] = regs
+		rule.deniedFlagValue[flag] = regs
+	}
+	if len(rule.Positionals) > 0 && len(rule.PermittedNoun) > 0 {
+		return fmt.Errorf("only one of permittedNouns and positionals may be set")
+	}
+	for _, pos := range rule.Positionals {
+		if pos == nil {
+			return fmt.Errorf("positionals: empty slot")
+		}
+		if err := pos.build(); err != nil {
+			return err
+		}
+	}
+	for flag, fa := range rule.FlagArgs {
+		if err := rule.buildFlagArg(flag, fa); err != nil {
+			return err
+		}
+	}
+	for name, sub := range rule.Subcommands {
+		// `name:` with nothing below it is a subcommand which takes no arguments
+		if sub == nil {
+			sub = &Subcommand{}
+			rule.Subcommands[name] = sub
+		}
+		if err := sub.buildRegex(); err != nil {
+			return fmt.Errorf("subcommand %s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+func (exec *Exec) parseDurations() error {
+	var err error
+	if exec.Timeout != "" {
+		if exec.timeout, err = time.ParseDuration(exec.Timeout); err != nil {
+			return fmt.Errorf("Invalid timeout for %s: %v", exec.CmdName, err)
+		}
+	}
+	if exec.GracePeriod != "" {
+		if exec.gracePeriod, err = time.ParseDuration(exec.GracePeriod); err != nil {
+			return fmt.Errorf("Invalid gracePeriod for %s: %v", exec.CmdName, err)
+		}
+	}
+	return nil
+}
+
+// execOptions returns how the command should be run for the request
+func (exec *Exec) execOptions(in *rpcapi.ExecRequest) util.ExecOptions {
+	timeout := exec.timeout
+	requested := time.Duration(in.TimeoutSeconds) * time.Second
+	if requested > 0 && (timeout == 0 || requested < timeout) {
+		timeout = requested
+	}
+	return util.ExecOptions{
+		Timeout:              timeout,
+		GracePeriod:          exec.gracePeriod,
+		Limits:               exec.limits,
+		Privileges:           exec.privileges,
+		MaxOutputBytes:       exec.MaxOutputBytes,
+		OutputBytesPerSecond: exec.OutputBytesPerSecond,
+	}
+}
+
+func (rule *ArgRule) buildFlagArg(flag string, fa *FlagArg) error {
+	if fa == nil {
+		return fmt.Errorf("flagArgs %s: must set arg or values", flag)
+	}
+	if fa.Arg == "" {
+		fa.Arg = FlagArgRequired
+	}
+	if fa.Arg != FlagArgNone && fa.Arg != FlagArgOptional && fa.Arg != FlagArgRequired {
+		return fmt.Errorf("flagArgs %s: arg must be one of none, optional or required: %s", flag, fa.Arg)
+	}
+	if fa.Arg != FlagArgNone && len(fa.Values) == 0 && fa.Path == nil {
+		return fmt.Errorf("flagArgs %s: a flag which takes a value must list the values or path permitted", flag)
+	}
+	if fa.Path != nil {
+		if err := fa.Path.build(); err != nil {
+			return fmt.Errorf("flagArgs %s: %v", flag, err)
+		}
+	}
+	if _, ok := rule.PermittedLong[flag]; ok {
+		return fmt.Errorf("flagArgs %s: also listed in permittedLongFlags", flag)
+	}
+	for _, short := range rule.PermittedShort {
+		if short == flag {
+			return fmt.Errorf("flagArgs %s: also listed in permittedShortFlags", flag)
+		}
 	}
-	regs, err := stringsToRe(exec.PermittedNoun)
+	regs, err := stringsToRe(fa.Values)
 	if err != nil {
 		return err
 	}
-	exec.permittedNoun = regs
+	fa.values = regs
 	return nil
 }
 
-// authz checks if the requestor has permission to run the command in question
-func (exec *Exec) authz(ctx context.Context) error {
+// info describes the arguments permitted by the rule and its subcommands
+func (rule *ArgRule) info(cmdName string) *rpcapi.CommandInfo {
+	flags := make([]string, 0, len(rule.PermittedLong))
+	for flag := range rule.PermittedLong {
+		flags = append(flags, flag)
+	}
+	sort.Strings(flags)
+	longFlags := make([]*rpcapi.LongFlag, 0, len(flags))
+	for _, flag := range flags {
+		longFlags = append(longFlags, &rpcapi.LongFlag{
+			Name:   flag,
+			Values: rule.PermittedLong[flag],
+		})
+	}
+	valueFlags := make([]string, 0, len(rule.FlagArgs))
+	for flag := range rule.FlagArgs {
+		valueFlags = append(valueFlags, flag)
+	}
+	sort.Strings(valueFlags)
+	flagArgs := make([]*rpcapi.FlagArg, 0, len(valueFlags))
+	for _, flag := range valueFlags {
+		flagArgs = append(flagArgs, &rpcapi.FlagArg{
+			Name:   flag,
+			Arg:    rule.FlagArgs[flag].Arg,
+			Values: rule.FlagArgs[flag].Values,
+			Path:   rule.FlagArgs[flag].Path.info(),
+		})
+	}
+	deniedFlags := make([]string, 0, len(rule.DeniedFlagValues))
+	for flag := range rule.DeniedFlagValues {
+		deniedFlags = append(deniedFlags, flag)
+	}
+	sort.Strings(deniedFlags)
+	deniedValues := make([]*rpcapi.LongFlag, 0, len(deniedFlags))
+	for _, flag := range deniedFlags {
+		deniedValues = append(deniedValues, &rpcapi.LongFlag{
+			Name:   flag,
+			Values: rule.DeniedFlagValues[flag],
+		})
+	}
+	positionals := make([]*rpcapi.Positional, 0, len(rule.Positionals))
+	for _, pos := range rule.Positionals {
+		positionals = append(positionals, &rpcapi.Positional{
+			Name:   pos.Name,
+			Values: pos.Values,
+			Min:    uint32(pos.min),
+			Max:    int32(pos.max),
+			Path:   pos.Path.info(),
+		})
+	}
+	subNames := make([]string, 0, len(rule.Subcommands))
+	for name := range rule.Subcommands {
+		subNames = append(subNames, name)
+	}
+	sort.Strings(subNames)
+	subcommands := make([]*rpcapi.CommandInfo, 0, len(subNames))
+	for _, name := range subNames {
+		subcommands = append(subcommands, rule.Subcommands[name].info(name))
+	}
+	return &rpcapi.CommandInfo{
+		CmdName:             cmdName,
+		RequiredFlags:       rule.Required,
+		PermittedShortFlags: rule.PermittedShort,
+		PermittedLongFlags:  longFlags,
+		PermittedNouns:      rule.PermittedNoun,
+		FlagArgs:            flagArgs,
+		Positionals:         positionals,
+		DeniedFlags:         rule.DeniedFlags,
+		DeniedNouns:         rule.DeniedNouns,
+		DeniedFlagValues:    deniedValues,
+		Subcommands:         subcommands,
+	}
+}
+
+// info describes the command for ListCommands
+func (exec *Exec) info() *rpcapi.CommandInfo {
+	info := exec.ArgRule.info(exec.CmdName)
+	info.Stdin = exec.Stdin
+	info.Tty = exec.TTY
+	info.Timeout = exec.Timeout
+	info.MaxArgs = uint32(exec.MaxArgs)
+	info.MaxArgLength = uint32(exec.MaxArgLength)
+	info.Aliases = exec.Aliases
+	info.BinaryPath = exec.BinaryPath
+	info.PrependArgs = exec.PrependArgs
+	info.AppendArgs = exec.AppendArgs
+	info.HostNamespaces = exec.hostNamespaces
+	info.TargetNamespaces = exec.targetNamespaces
+	return info
+}
+
+// authz checks if the requestor has permission to run the command in question.
+// The error of a clause which failed says which one it was.
+func (auth *ExecAuth) authz(ctx context.Context, vars *authVars) error {
+	if len(auth.AllOf) > 0 {
+		for i, clause := range auth.AllOf {
+			if err := clause.authz(ctx, vars); err != nil {
+				return fmt.Errorf("allOf[%d]: %v", i, err)
+			}
+		}
+		return nil
+	}
+	if len(auth.AnyOf) > 0 {
+		errs := make([]string, 0, len(auth.AnyOf))
+		for i, clause := range auth.AnyOf {
+			err := clause.authz(ctx, vars)
+			if err == nil {
+				return nil
+			}
+			errs = append(errs, fmt.Sprintf("anyOf[%d]: %v", i, err))
+		}
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	attrs, nonResource, err := auth.attributes(vars)
+	if err != nil {
+		return err
+	}
+	return checkAccess(ctx, attrs, nonResource)
+}
+
+// checkAccess asks the API server, with a SubjectAccessReview, if the
+// requestor may do what is described by attrs or, if it is nil, nonResource
+func checkAccess(ctx context.Context, attrs *authzv1.ResourceAttributes, nonResource *authzv1.NonResourceAttributes) error {
 	tokenInfo := util.GetToken(ctx)
-	clientset := util.GetClientset(ctx)
 
 	// contortions to Change authenticationv1.ExtraValue into authorizationv1.ExtraValue
 	// even though they are both just strings :-(
@@ -88,47 +462,90 @@ func (exec *Exec) authz(ctx context.Context) error {
 	}
 	sar := &authzv1.SubjectAccessReview{
 		Spec: authzv1.SubjectAccessReviewSpec{
-			User:   tokenInfo.Status.User.Username,
-			Groups: tokenInfo.Status.User.Groups,
-			UID:    tokenInfo.Status.User.UID,
-			Extra:  authzExtras,
-			ResourceAttributes: &authzv1.ResourceAttributes{
-				Namespace: exec.Auth.Namespace,
-				Verb:      exec.Auth.Verb,
-				Resource:  exec.Auth.Resource,
-				Version:   exec.Auth.Version,
-			},
+			User:                  tokenInfo.Status.User.Username,
+			Groups:                tokenInfo.Status.User.Groups,
+			UID:                   tokenInfo.Status.User.UID,
+			Extra:                 authzExtras,
+			ResourceAttributes:    attrs,
+			NonResourceAttributes: nonResource,
 		},
 	}
 
-	sar, err := clientset.AuthorizationV1().SubjectAccessReviews().Create(sar)
+	allowed, err := reviewAccess(ctx, sar)
 	if err != nil {
 		return err
 	}
 
-	if !sar.Status.Allowed {
-		return fmt.Errorf("user: %q is not allowed to %q %q in the %q namespace. Refusing", tokenInfo.Status.User.Username, exec.Auth.Verb, exec.Auth.Resource, exec.Auth.Namespace)
+	if !allowed {
+		if attrs == nil {
+			return fmt.Errorf("user: %q is not allowed to %q %q. Refusing", tokenInfo.Status.User.Username, nonResource.Verb, nonResource.Path)
+		}
+		if attrs.Namespace == "" {
+			return fmt.Errorf("user: %q is not allowed to %q %q. Refusing", tokenInfo.Status.User.Username, attrs.Verb, resourceString(attrs))
+		}
+		return fmt.Errorf("user: %q is not allowed to %q %q in the %q namespace. Refusing", tokenInfo.Status.User.Username, attrs.Verb, resourceString(attrs), attrs.Namespace)
 	}
 
 	return nil
 }
 
+// createAccessReview asks the API server to answer the SubjectAccessReview
+var createAccessReview = func(ctx context.Context, sar *authzv1.SubjectAccessReview) (*authzv1.SubjectAccessReview, error) {
+	return util.GetClientset(ctx).AuthorizationV1().SubjectAccessReviews().Create(sar)
+}
+
+// reviewAccess returns the answer to the SubjectAccessReview, from the cache
+// if the same review was answered recently
+func reviewAccess(ctx context.Context, sar *authzv1.SubjectAccessReview) (bool, error) {
+	cache := util.GetAccessCache(ctx)
+	spec, err := json.Marshal(sar.Spec)
+	if err != nil {
+		return false, err
+	}
+	key := fmt.Sprintf("%x", sha256.Sum256(spec))
+	if allowed, ok := cache.Get(key); ok {
+		return allowed.(bool), nil
+	}
+
+	sar, err = createAccessReview(ctx, sar)
+	if err != nil {
+		return false, err
+	}
+	cache.Add(key, sar.Status.Allowed, sar.Status.Allowed)
+	return sar.Status.Allowed, nil
+}
+
 // Server is used to implement the RemoteExecServer
 type sndCmd struct {
 	commands map[string][]Exec
+	// nodeName and nodeLabels are the node the server runs on, if known
+	nodeName   string
+	nodeLabels map[string]string
+	// nodeAuth, if set, must pass for every request along with the auth
+	// of the command
+	nodeAuth *ExecAuth
+	// limiter and userLimiters, if set, limit the commands run at once on
+	// the node and by each user
+	limiter      *limiter
+	userLimiters *userLimiters
 }
 
 // return the Exec and a bool indicating if it was found
-func (s *sndCmd) getExec(cmdName string, cmdArgs []string, ctx context.Context) (Exec, error) {
+func (s *sndCmd) getExec(cmdName string, cmdArgs []string, t *target, ctx context.Context) (Exec, error) {
 	commands, ok := s.commands[cmdName]
 	if !ok {
 		return Exec{}, fmt.Errorf("Command not found: %s", cmdName)
 	}
+	if err := s.nodeAuthz(ctx, t); err != nil {
+		return Exec{}, grpc.Errorf(codes.PermissionDenied, "%v", err)
+	}
 	var firstAuthErr error
 	var err error
 	for _, cmd := range commands {
-		if err = cmd.valid(cmdName, cmdArgs); err == nil {
-			if err = cmd.authz(ctx); err == nil {
+		var auth *ExecAuth
+		var nouns []string
+		if auth, nouns, err = cmd.valid(cmdName, cmdArgs, t); err == nil {
+			if err = auth.authz(ctx, s.authVars(nouns, t)); err == nil {
 				// We found a cmd the user could execute. Go Go Go
 				return cmd, nil
 			}
@@ -160,12 +577,197 @@ func (s *sndCmd) SendExec(in *rpcapi.ExecRequest, stream rpcapi.Exec_SendExecSer
 	cmdArgsString := fmt.Sprintf("%#v", cmdArgs)
 	util.AddAuditData(ctx, "command.args", cmdArgsString)
 
-	_, err := s.getExec(cmdName, cmdArgs, ctx)
+	if in.Tty {
+		return grpc.Errorf(codes.InvalidArgument, "A tty requires StreamExec")
+	}
+
+	t, err := s.resolveTarget(ctx, in.Target)
 	if err != nil {
 		return err
 	}
+	exec, err := s.getExec(cmdName, cmdArgs, t, ctx)
+	if err != nil {
+		return err
+	}
+	binary, args, err := exec.commandLine(cmdArgs, t)
+	if err != nil {
+		return err
+	}
+	util.AddAuditData(ctx, "command.binary", binary)
+
+	release, err := s.admit(ctx, &exec)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return util.ExecuteCmdNamespace(binary, args, exec.namespacesFor(t), exec.execOptions(in), stream)
+}
+
+// StreamExec receives the command from the client in the first message and
+// then executes it server-side, feeding the stdin from every later message to
+// the process. Only commands which opt in with `stdin: true` may be used, or
+// `tty: true` if the client asked for a terminal.
+func (s *sndCmd) StreamExec(stream rpcapi.Exec_StreamExecServer) error {
+	in, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	if in.Request == nil {
+		return grpc.Errorf(codes.InvalidArgument, "First message must contain the request")
+	}
+	var cmdName = in.Request.CmdName
+	var cmdArgs = in.Request.CmdArgs
+
+	ctx := stream.Context()
+
+	util.AddAuditData(ctx, "command.name", cmdName)
+	cmdArgsString := fmt.Sprintf("%#v", cmdArgs)
+	util.AddAuditData(ctx, "command.args", cmdArgsString)
+	util.AddAuditData(ctx, "command.stdin", "true")
+	util.AddAuditData(ctx, "command.tty", fmt.Sprintf("%t", in.Request.Tty))
+
+	t, err := s.resolveTarget(ctx, in.Request.Target)
+	if err != nil {
+		return err
+	}
+	exec, err := s.getExec(cmdName, cmdArgs, t, ctx)
+	if err != nil {
+		return err
+	}
+	binary, args, err := exec.commandLine(cmdArgs, t)
+	if err != nil {
+		return err
+	}
+	util.AddAuditData(ctx, "command.binary", binary)
+
+	if !in.Request.Tty && !exec.Stdin {
+		return grpc.Errorf(codes.PermissionDenied, "Command does not accept stdin: %s", cmdName)
+	}
+	if in.Request.Tty && !exec.TTY {
+		return grpc.Errorf(codes.PermissionDenied, "Command may not be run on a tty: %s", cmdName)
+	}
+	release, err := s.admit(ctx, &exec)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	opts := exec.execOptions(in.Request)
+	if !in.Request.Tty {
+		opts.Stdin = util.NewStdinReader(stream, in, nil)
+		return util.ExecuteCmdNamespace(binary, args, exec.namespacesFor(t), opts, stream)
+	}
 
-	return util.ExecuteCmdInitNS(cmdName, cmdArgs, stream)
+	size := in.Request.WindowSize
+	if size == nil {
+		size = &rpcapi.WindowSize{Rows: 24, Cols: 80}
+	}
+	resize := make(chan *rpcapi.WindowSize)
+	opts.Stdin = util.NewStdinReader(stream, in, resize)
+	opts.TTY = size
+	opts.Resize = resize
+	return util.ExecuteCmdNamespace(binary, args, exec.namespacesFor(t), opts, stream)
+}
+
+// ListCommands returns every command the caller is authorized to run.
+func (s *sndCmd) ListCommands(ctx context.Context, in *rpcapi.ListCommandsRequest) (*rpcapi.ListCommandsReply, error) {
+	cmdNames := make([]string, 0, len(s.commands))
+	for cmdName := range s.commands {
+		cmdNames = append(cmdNames, cmdName)
+	}
+	sort.Strings(cmdNames)
+
+	if err := s.nodeAuthz(ctx, nil); err != nil {
+		return nil, grpc.Errorf(codes.PermissionDenied, "%v", err)
+	}
+	reply := &rpcapi.ListCommandsReply{}
+	// There are no nouns or target, a command whose name refers to them is
+	// not listed
+	vars := s.authVars(nil, nil)
+	for _, cmdName := range cmdNames {
+		for _, exec := range s.commands[cmdName] {
+			// aliases are listed along with the command
+			if exec.CmdName != cmdName {
+				continue
+			}
+			if err := exec.Auth.authz(ctx, vars); err != nil {
+				continue
+			}
+			reply.Commands = append(reply.Commands, exec.info())
+		}
+	}
+	return reply, nil
+}
+
+// ValidateExec checks the request against every rule for the command, and
+// the caller's permission to use each rule, without running anything.
+func (s *sndCmd) ValidateExec(ctx context.Context, in *rpcapi.ExecRequest) (*rpcapi.ValidateExecReply, error) {
+	var cmdName = in.CmdName
+	var cmdArgs = in.CmdArgs
+
+	util.AddAuditData(ctx, "command.name", cmdName)
+	cmdArgsString := fmt.Sprintf("%#v", cmdArgs)
+	util.AddAuditData(ctx, "command.args", cmdArgsString)
+	util.AddAuditData(ctx, "command.dryRun", "true")
+
+	commands, ok := s.commands[cmdName]
+	if !ok {
+		return nil, grpc.Errorf(codes.NotFound, "Command not found: %s", cmdName)
+	}
+	t, err := s.resolveTarget(ctx, in.Target)
+	if err != nil {
+		return nil, err
+	}
+
+	var nodeCheck *rpcapi.CheckResult
+	if s.nodeAuth != nil {
+		nodeCheck = &rpcapi.CheckResult{
+			Kind: rpcapi.CheckResult_AUTHZ,
+			Arg:  "node " + s.nodeAuth.describe(s.authVars(nil, t)),
+		}
+		if err := s.nodeAuthz(ctx, t); err != nil {
+			nodeCheck.Message = err.Error()
+		} else {
+			nodeCheck.Passed = true
+		}
+	}
+
+	reply := &rpcapi.ValidateExecReply{}
+	for _, exec := range commands {
+		checks, auth, nouns, allowed := exec.explain(cmdArgs, t)
+		if nodeCheck != nil {
+			checks = append(checks, nodeCheck)
+			allowed = allowed && nodeCheck.Passed
+		}
+		vars := s.authVars(nouns, t)
+		authzCheck := &rpcapi.CheckResult{
+			Kind: rpcapi.CheckResult_AUTHZ,
+			Arg:  auth.describe(vars),
+		}
+		if err := auth.authz(ctx, vars); err != nil {
+			authzCheck.Message = err.Error()
+			allowed = false
+		} else {
+			authzCheck.Passed = true
+		}
+		// Only those allowed to use the rule may see it, the same as
+		// ListCommands, so the others only learn that they are not
+		if !authzCheck.Passed {
+			reply.Rules = append(reply.Rules, &rpcapi.RuleResult{
+				Checks: []*rpcapi.CheckResult{authzCheck},
+			})
+			continue
+		}
+		reply.Rules = append(reply.Rules, &rpcapi.RuleResult{
+			Rule:    exec.info(),
+			Allowed: allowed,
+			Checks:  append(checks, authzCheck),
+		})
+		if allowed {
+			reply.Allowed = true
+		}
+	}
+	return reply, nil
 }
 
 func initExecConfig(in interface{}) error {
@@ -176,16 +778,61 @@ func initExecConfig(in interface{}) error {
 	if err := exec.buildRegex(); err != nil {
 		return err
 	}
+	if err := exec.parseDurations(); err != nil {
+		return err
+	}
+	if err := exec.buildBinary(); err != nil {
+		return err
+	}
+	if err := exec.buildNamespaces(); err != nil {
+		return err
+	}
+	if err := exec.buildLimits(); err != nil {
+		return err
+	}
+	if err := exec.buildPrivileges(); err != nil {
+		return err
+	}
+	if err := exec.buildConcurrency(); err != nil {
+		return err
+	}
+	if err := exec.buildAuth(); err != nil {
+		return err
+	}
+	for _, cond := range exec.When {
+		if cond == nil {
+			return fmt.Errorf("when: empty condition")
+		}
+		if err := cond.build(); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-func NewExec(cfgDir string) (*sndCmd, error) {
+// NewExec loads the commands in cfgDir which are enabled on this node.
+// nodeName and nodeLabels may be empty if the node is not known, in which
+// case commands conditional on node labels are not loaded and containers can
+// only be targeted by pod.
+func NewExec(cfgDir string, nodeName string, nodeLabels map[string]string) (*sndCmd, error) {
+	cfgDir = filepath.Join(cfgDir, "command")
+	config, err := loadConfig(cfgDir)
+	if err != nil {
+		return nil, err
+	}
 	newCmd := &sndCmd{
-		commands: map[string][]Exec{},
+		commands:     map[string][]Exec{},
+		nodeName:     nodeName,
+		nodeLabels:   nodeLabels,
+		nodeAuth:     config.NodeAuth,
+		limiter:      newLimiter(limitNode, config.Concurrency),
+		userLimiters: newUserLimiters(config.ConcurrencyPerUser),
+	}
+	if config.NodeAuth != nil && nodeName == "" {
+		fmt.Printf("  The node is not known, requests will be refused if nodeAuth refers to it\n")
 	}
-	cfgDir = filepath.Join(cfgDir, "command")
 	var commandConfigs []Exec
-	err := util.LoadConfig(cfgDir, initExecConfig, &commandConfigs)
+	err = util.LoadConfig(cfgDir, initExecConfig, &commandConfigs)
 	if err != nil {
 		return nil, err
 	}
@@ -194,9 +841,15 @@ func NewExec(cfgDir string) (*sndCmd, error) {
 		return nil, fmt.Errorf("No commands defined in: %s\n", cfgDir)
 	}
 
+	node := newNodeInfo(nodeLabels)
 	for _, cmd := range commandConfigs {
-		cmdName := cmd.CmdName
-		newCmd.commands[cmdName] = append(newCmd.commands[cmdName], cmd)
+		if err := cmd.enabled(node); err != nil {
+			fmt.Printf("  Not loading command %s: %v\n", cmd.CmdName, err)
+			continue
+		}
+		for _, cmdName := range append([]string{cmd.CmdName}, cmd.Aliases...) {
+			newCmd.commands[cmdName] = append(newCmd.commands[cmdName], cmd)
+		}
 	}
 	return newCmd, nil
 }
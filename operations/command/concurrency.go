@@ -0,0 +1,292 @@
+package command
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	yaml "gopkg.in/yaml.v2"
+
+	"github.com/eparis/admin-rpc/operations/util"
+)
+
+// The limits a command may have to wait for, the limit label of the metrics
+const (
+	limitNode    = "node"
+	limitCommand = "command"
+	limitUser    = "user"
+)
+
+var (
+	queuedCommands = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "admin_rpc_commands_queued",
+		Help: "Number of commands waiting for others to finish, by the limit they wait for.",
+	}, []string{"limit"})
+	rejectedCommands = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "admin_rpc_commands_rejected_total",
+		Help: "Number of commands rejected as too many were running and queued, by the limit and command.",
+	}, []string{"limit", "command"})
+)
+
+func init() {
+	prometheus.MustRegister(queuedCommands, rejectedCommands)
+}
+
+// Config is the configuration of all commands, read from the file called
+// config next to them
+type Config struct {
+	// Concurrency limits the commands run at once on the node
+	Concurrency *Concurrency `json:"concurrency,omitempty" yaml:"concurrency,omitempty"`
+	// ConcurrencyPerUser limits the commands each user runs at once
+	ConcurrencyPerUser *Concurrency `json:"concurrencyPerUser,omitempty" yaml:"concurrencyPerUser,omitempty"`
+	// NodeAuth, if set, must pass for every request, along with the auth of
+	// the command, so access can be granted node by node
+	NodeAuth *ExecAuth `json:"nodeAuth,omitempty" yaml:"nodeAuth,omitempty"`
+}
+
+// Concurrency limits how many commands run at once
+type Concurrency struct {
+	// Max is the most commands run at once
+	Max int `json:"max" yaml:"max"`
+	// Queue is how many more commands may wait for one of them to finish.
+	// Once the queue is full commands are rejected with ResourceExhausted.
+	Queue int `json:"queue,omitempty" yaml:"queue,omitempty"`
+}
+
+func (c *Concurrency) validate() error {
+	if c.Max <= 0 {
+		return fmt.Errorf("max must be more than 0: %d", c.Max)
+	}
+	if c.Queue < 0 {
+		return fmt.Errorf("queue must not be negative: %d", c.Queue)
+	}
+	return nil
+}
+
+// loadConfig reads the config file in cfgDir. It is optional.
+func loadConfig(cfgDir string) (*Config, error) {
+	config := &Config{}
+	buf, err := ioutil.ReadFile(filepath.Join(cfgDir, "config"))
+	if os.IsNotExist(err) {
+		return config, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(buf, config); err != nil {
+		return nil, err
+	}
+	if config.Concurrency != nil {
+		if err := config.Concurrency.validate(); err != nil {
+			return nil, fmt.Errorf("concurrency: %v", err)
+		}
+	}
+	if config.ConcurrencyPerUser != nil {
+		if err := config.ConcurrencyPerUser.validate(); err != nil {
+			return nil, fmt.Errorf("concurrencyPerUser: %v", err)
+		}
+	}
+	if config.NodeAuth != nil {
+		if err := config.NodeAuth.build(); err != nil {
+			return nil, fmt.Errorf("nodeAuth: %v", err)
+		}
+	}
+	return config, nil
+}
+
+// limiter runs at most max commands at once, with at most queue more waiting
+type limiter struct {
+	kind  string
+	slots chan struct{}
+	queue int
+
+	lock   sync.Mutex
+	queued int
+}
+
+func newLimiter(kind string, c *Concurrency) *limiter {
+	if c == nil {
+		return nil
+	}
+	return &limiter{
+		kind:  kind,
+		slots: make(chan struct{}, c.Max),
+		queue: c.Queue,
+	}
+}
+
+// tryAcquire takes a slot if one is free, without waiting
+func (l *limiter) tryAcquire() bool {
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// acquire waits, if there is room in the queue, until the command may run.
+// It returns false if the queue is full.
+func (l *limiter) acquire(ctx context.Context) (bool, error) {
+	if l.tryAcquire() {
+		return true, nil
+	}
+
+	l.lock.Lock()
+	if l.queued >= l.queue {
+		l.lock.Unlock()
+		return false, nil
+	}
+	l.queued++
+	l.lock.Unlock()
+	queuedCommands.WithLabelValues(l.kind).Inc()
+	defer func() {
+		l.lock.Lock()
+		l.queued--
+		l.lock.Unlock()
+		queuedCommands.WithLabelValues(l.kind).Dec()
+	}()
+
+	select {
+	case l.slots <- struct{}{}:
+		return true, nil
+	case <-ctx.Done():
+		return false, ctx.Err()
+	}
+}
+
+func (l *limiter) release() {
+	<-l.slots
+}
+
+// userLimiters has a limiter for every user running or waiting to run a
+// command. Limiters are dropped once nobody uses them.
+type userLimiters struct {
+	concurrency *Concurrency
+	lock        sync.Mutex
+	limiters    map[string]*limiter
+	users       map[string]int
+}
+
+func newUserLimiters(c *Concurrency) *userLimiters {
+	if c == nil {
+		return nil
+	}
+	return &userLimiters{
+		concurrency: c,
+		limiters:    map[string]*limiter{},
+		users:       map[string]int{},
+	}
+}
+
+func (u *userLimiters) get(user string) *limiter {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	l, ok := u.limiters[user]
+	if !ok {
+		l = newLimiter(limitUser, u.concurrency)
+		u.limiters[user] = l
+	}
+	u.users[user]++
+	return l
+}
+
+func (u *userLimiters) put(user string) {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	if u.users[user]--; u.users[user] == 0 {
+		delete(u.users, user)
+		delete(u.limiters, user)
+	}
+}
+
+func (exec *Exec) buildConcurrency() error {
+	if exec.Concurrency == nil {
+		return nil
+	}
+	if err := exec.Concurrency.validate(); err != nil {
+		return fmt.Errorf("concurrency for %s: %v", exec.CmdName, err)
+	}
+	exec.limiter = newLimiter(limitCommand, exec.Concurrency)
+	return nil
+}
+
+// rejected is the error for a command rejected by l as its queue is full
+func (l *limiter) rejected(cmdName, user string) error {
+	rejectedCommands.WithLabelValues(l.kind, cmdName).Inc()
+	switch l.kind {
+	case limitUser:
+		return grpc.Errorf(codes.ResourceExhausted, "Too many commands of %s are running, try again later", user)
+	case limitCommand:
+		return grpc.Errorf(codes.ResourceExhausted, "Too many %s commands are running, try again later", cmdName)
+	}
+	return grpc.Errorf(codes.ResourceExhausted, "Too many commands are running on this node, try again later")
+}
+
+// admit waits until the command may run under the limits of the user, the
+// command and the node. No slot is held while waiting for another, so a
+// command queued for the node does not hold up the other commands of its
+// user or of the same kind. Once the slot waited for is free the others are
+// taken if they are free too, or it is given back to wait for the next. The
+// returned func must be called once the command finished.
+func (s *sndCmd) admit(ctx context.Context, exec *Exec) (func(), error) {
+	limiters := []*limiter{}
+	user := ""
+	done := func() {}
+	if s.userLimiters != nil {
+		user = util.GetToken(ctx).Status.User.Username
+		limiters = append(limiters, s.userLimiters.get(user))
+		done = func() { s.userLimiters.put(user) }
+	}
+	for _, l := range []*limiter{exec.limiter, s.limiter} {
+		if l != nil {
+			limiters = append(limiters, l)
+		}
+	}
+
+	var wait *limiter
+	for {
+		held := []*limiter{}
+		if wait != nil {
+			ok, err := wait.acquire(ctx)
+			if err != nil {
+				done()
+				return nil, err
+			}
+			if !ok {
+				done()
+				return nil, wait.rejected(exec.CmdName, user)
+			}
+			held = append(held, wait)
+		}
+		waited := wait
+		wait = nil
+		for _, l := range limiters {
+			if l == waited {
+				continue
+			}
+			if !l.tryAcquire() {
+				wait = l
+				break
+			}
+			held = append(held, l)
+		}
+		if wait == nil {
+			return func() {
+				for _, l := range held {
+					l.release()
+				}
+				done()
+			}, nil
+		}
+		for _, l := range held {
+			l.release()
+		}
+	}
+}
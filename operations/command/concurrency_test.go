@@ -0,0 +1,194 @@
+package command
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	authnv1 "k8s.io/api/authentication/v1"
+
+	"github.com/eparis/admin-rpc/operations/util"
+)
+
+// userContext is the context of a request by user
+func userContext(ctx context.Context, user string) context.Context {
+	token := &authnv1.TokenReview{}
+	token.Status.User.Username = user
+	return util.PutToken(ctx, token)
+}
+
+// canceled is a context which is already done, so acquire returns at once
+// where it would otherwise wait in the queue
+func canceled() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	return ctx
+}
+
+// fill takes n slots of l
+func fill(l *limiter, n int) {
+	for i := 0; i < n; i++ {
+		l.slots <- struct{}{}
+	}
+}
+
+func TestLimiterAcquire(t *testing.T) {
+	tests := []struct {
+		max   int
+		queue int
+		held  int
+		ok    bool
+		// queued is if the command would have waited
+		queued bool
+	}{
+		{1, 0, 0, true, false},
+		{2, 0, 1, true, false},
+		{1, 0, 1, false, false},
+		{1, 1, 1, false, true},
+		{2, 3, 2, false, true},
+	}
+	for _, test := range tests {
+		l := newLimiter(limitNode, &Concurrency{Max: test.max, Queue: test.queue})
+		fill(l, test.held)
+		ok, err := l.acquire(canceled())
+		if ok != test.ok || (err != nil) != test.queued {
+			t.Errorf("%+v: got %t, %v", test, ok, err)
+		}
+		if l.queued != 0 {
+			t.Errorf("%+v: %d still queued", test, l.queued)
+		}
+	}
+}
+
+func TestLimiterQueue(t *testing.T) {
+	l := newLimiter(limitNode, &Concurrency{Max: 1, Queue: 1})
+	fill(l, 1)
+	acquired := make(chan bool)
+	go func() {
+		ok, _ := l.acquire(context.Background())
+		acquired <- ok
+	}()
+	for queued := 0; queued == 0; time.Sleep(time.Millisecond) {
+		l.lock.Lock()
+		queued = l.queued
+		l.lock.Unlock()
+	}
+	// The queue is full
+	if ok, err := l.acquire(context.Background()); ok || err != nil {
+		t.Errorf("expected to be rejected, got %t, %v", ok, err)
+	}
+	l.release()
+	if !<-acquired {
+		t.Errorf("expected the queued command to run")
+	}
+}
+
+func TestAdmit(t *testing.T) {
+	tests := []struct {
+		name string
+		// node, command and user are the limits, nil for none
+		node    *Concurrency
+		command *Concurrency
+		user    *Concurrency
+		// nodeHeld and commandHeld are the slots taken by other users
+		nodeHeld    int
+		commandHeld int
+		// code is the error expected, codes.Canceled if the command
+		// would have waited
+		code codes.Code
+		msg  string
+	}{
+		{name: "no limits", code: codes.OK},
+		{name: "free", node: &Concurrency{Max: 1}, command: &Concurrency{Max: 1}, user: &Concurrency{Max: 1}, code: codes.OK},
+		{name: "node full", node: &Concurrency{Max: 1}, nodeHeld: 1, code: codes.ResourceExhausted, msg: "Too many commands are running on this node"},
+		{name: "command full", command: &Concurrency{Max: 2}, commandHeld: 2, code: codes.ResourceExhausted, msg: "Too many test commands are running"},
+		{name: "node queued", node: &Concurrency{Max: 1, Queue: 1}, user: &Concurrency{Max: 1}, nodeHeld: 1, code: codes.Canceled},
+		{name: "command queued", node: &Concurrency{Max: 1}, command: &Concurrency{Max: 1, Queue: 1}, commandHeld: 1, code: codes.Canceled},
+	}
+	for _, test := range tests {
+		s := &sndCmd{
+			limiter:      newLimiter(limitNode, test.node),
+			userLimiters: newUserLimiters(test.user),
+		}
+		exec := &Exec{CmdName: "test", limiter: newLimiter(limitCommand, test.command)}
+		if s.limiter != nil {
+			fill(s.limiter, test.nodeHeld)
+		}
+		if exec.limiter != nil {
+			fill(exec.limiter, test.commandHeld)
+		}
+		ctx := userContext(context.Background(), "alice")
+		if test.code == codes.Canceled {
+			ctx = userContext(canceled(), "alice")
+		}
+		release, err := s.admit(ctx, exec)
+		switch {
+		case test.code == codes.OK && err != nil:
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+		case test.code == codes.Canceled && err != context.Canceled:
+			t.Errorf("%s: expected to wait, got: %v", test.name, err)
+		case test.code == codes.ResourceExhausted && (grpc.Code(err) != test.code || !strings.Contains(grpc.ErrorDesc(err), test.msg)):
+			t.Errorf("%s: expected %s %q, got: %v", test.name, test.code, test.msg, err)
+		}
+		if release != nil {
+			release()
+		}
+		// Nothing is held once the command finished or was refused
+		if s.limiter != nil && len(s.limiter.slots) != test.nodeHeld {
+			t.Errorf("%s: %d node slots held, expected %d", test.name, len(s.limiter.slots), test.nodeHeld)
+		}
+		if exec.limiter != nil && len(exec.limiter.slots) != test.commandHeld {
+			t.Errorf("%s: %d command slots held, expected %d", test.name, len(exec.limiter.slots), test.commandHeld)
+		}
+		if s.userLimiters != nil && len(s.userLimiters.limiters) != 0 {
+			t.Errorf("%s: user limiters left: %v", test.name, s.userLimiters.limiters)
+		}
+	}
+}
+
+// TestAdmitUserNotHeld checks a user waiting for the node does not keep
+// their own slot, so the rest of their commands are not held up too
+func TestAdmitUserNotHeld(t *testing.T) {
+	s := &sndCmd{
+		limiter:      newLimiter(limitNode, &Concurrency{Max: 1, Queue: 1}),
+		userLimiters: newUserLimiters(&Concurrency{Max: 1}),
+	}
+	exec := &Exec{CmdName: "test"}
+	fill(s.limiter, 1)
+	ctx := userContext(context.Background(), "alice")
+
+	admitted := make(chan func())
+	go func() {
+		release, err := s.admit(ctx, exec)
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		admitted <- release
+	}()
+	for queued := 0; queued == 0; time.Sleep(time.Millisecond) {
+		s.limiter.lock.Lock()
+		queued = s.limiter.queued
+		s.limiter.lock.Unlock()
+	}
+
+	// alice may run a command which does not need the node slot
+	s.userLimiters.lock.Lock()
+	user := s.userLimiters.limiters["alice"]
+	s.userLimiters.lock.Unlock()
+	if len(user.slots) != 0 {
+		t.Errorf("user slot held while waiting for the node")
+	}
+
+	s.limiter.release()
+	release := <-admitted
+	if len(user.slots) != 1 || len(s.limiter.slots) != 1 {
+		t.Errorf("expected the user and node slots to be held, got %d and %d", len(user.slots), len(s.limiter.slots))
+	}
+	release()
+	if len(s.limiter.slots) != 0 || len(s.userLimiters.limiters) != 0 {
+		t.Errorf("expected everything to be released")
+	}
+}
@@ -0,0 +1,177 @@
+package command
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// binPath is searched for binaries named without a `/`
+var binPath = []string{"/usr/local/sbin", "/usr/local/bin", "/usr/sbin", "/usr/bin", "/sbin", "/bin"}
+
+// Condition is one set of requirements a node must meet for a command to be
+// loaded. Every field which is set must match.
+type Condition struct {
+	// Binaries must exist and be executable in the mount namespace the
+	// command runs in. Names without a `/` are looked up in the usual sbin
+	// and bin directories.
+	Binaries []string `json:"binaries,omitempty" yaml:"binaries,omitempty"`
+	// NodeLabels must all be set on the node. An empty value only checks
+	// that the label exists.
+	NodeLabels map[string]string `json:"nodeLabels,omitempty" yaml:"nodeLabels,omitempty"`
+	// OS are patterns, one of which must match ID in the host's
+	// /etc/os-release, e.g. `^rhel$`
+	OS   []string `json:"os,omitempty" yaml:"os,omitempty"`
+	osID argRegex
+	// OSVersion are patterns, one of which must match VERSION_ID in the
+	// host's /etc/os-release, e.g. `^7\.`
+	OSVersion []string `json:"osVersion,omitempty" yaml:"osVersion,omitempty"`
+	osVersion argRegex
+}
+
+func (cond *Condition) build() error {
+	var err error
+	if len(cond.Binaries) == 0 && len(cond.NodeLabels) == 0 && len(cond.OS) == 0 && len(cond.OSVersion) == 0 {
+		return fmt.Errorf("when: empty condition")
+	}
+	if cond.osID, err = stringsToRe(cond.OS); err != nil {
+		return err
+	}
+	if cond.osVersion, err = stringsToRe(cond.OSVersion); err != nil {
+		return err
+	}
+	return nil
+}
+
+// nodeInfo is what conditions are checked against. labels is nil if the
+// node could not be read from the API server.
+type nodeInfo struct {
+	labels    map[string]string
+	osID      string
+	osVersion string
+	osErr     error
+}
+
+// newNodeInfo reads the OS of the host once so every command sees the same
+func newNodeInfo(labels map[string]string) *nodeInfo {
+	node := &nodeInfo{labels: labels}
+	node.osID, node.osVersion, node.osErr = readOSRelease(filepath.Join(hostRoot, "etc/os-release"))
+	return node
+}
+
+// readOSRelease returns ID and VERSION_ID from an os-release file
+func readOSRelease(path string) (string, string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", "", err
+	}
+	defer f.Close()
+	var id, version string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		i := strings.IndexByte(line, '=')
+		if i < 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, val := line[:i], line[i+1:]
+		if unquoted, err := strconv.Unquote(val); err == nil {
+			val = unquoted
+		} else {
+			val = strings.Trim(val, `'`)
+		}
+		switch key {
+		case "ID":
+			id = val
+		case "VERSION_ID":
+			version = val
+		}
+	}
+	return id, version, scanner.Err()
+}
+
+// findBinary reports if the binary exists under root and is executable
+func findBinary(root, name string) bool {
+	paths := []string{name}
+	if !strings.Contains(name, "/") {
+		paths = paths[:0]
+		for _, dir := range binPath {
+			paths = append(paths, filepath.Join(dir, name))
+		}
+	}
+	for _, path := range paths {
+		resolved, err := resolvePath(root, path)
+		if err != nil {
+			continue
+		}
+		fi, err := os.Stat(filepath.Join(root, resolved))
+		if err == nil && fi.Mode().IsRegular() && fi.Mode()&0111 != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// met returns why the node does not meet the condition, or nil if it does.
+// Binaries are looked for under root.
+func (cond *Condition) met(node *nodeInfo, root string) error {
+	for _, bin := range cond.Binaries {
+		if !findBinary(root, bin) {
+			return fmt.Errorf("binary not found: %s", bin)
+		}
+	}
+	if len(cond.NodeLabels) > 0 && node.labels == nil {
+		return fmt.Errorf("node labels are unknown, is NODE_NAME set?")
+	}
+	keys := make([]string, 0, len(cond.NodeLabels))
+	for key := range cond.NodeLabels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		val, ok := node.labels[key]
+		if !ok {
+			return fmt.Errorf("node label not set: %s", key)
+		}
+		if want := cond.NodeLabels[key]; want != "" && val != want {
+			return fmt.Errorf("node label %s is %q not %q", key, val, want)
+		}
+	}
+	if len(cond.osID) == 0 && len(cond.osVersion) == 0 {
+		return nil
+	}
+	if node.osErr != nil {
+		return fmt.Errorf("unable to read os-release: %v", node.osErr)
+	}
+	if len(cond.osID) > 0 && !cond.osID.valid(node.osID) {
+		return fmt.Errorf("os %q does not match", node.osID)
+	}
+	if len(cond.osVersion) > 0 && !cond.osVersion.valid(node.osVersion) {
+		return fmt.Errorf("os version %q does not match", node.osVersion)
+	}
+	return nil
+}
+
+// enabled returns why the command should not be loaded on this node, or nil
+// if it should
+func (exec *Exec) enabled(node *nodeInfo) error {
+	if exec.Enable != nil && !*exec.Enable {
+		return fmt.Errorf("not enabled")
+	}
+	if len(exec.When) == 0 {
+		return nil
+	}
+	var reasons []string
+	for _, cond := range exec.When {
+		err := cond.met(node, exec.root(nil))
+		if err == nil {
+			return nil
+		}
+		reasons = append(reasons, err.Error())
+	}
+	return fmt.Errorf("no condition met: %s", strings.Join(reasons, "; "))
+}
@@ -0,0 +1,89 @@
+package command
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestReadOSRelease(t *testing.T) {
+	tests := []struct {
+		file    string
+		id      string
+		version string
+	}{
+		{"rhel7", "rhel", "7.6"},
+		{"fedora", "fedora", "29"},
+		{"ubuntu", "ubuntu", "18.04"},
+		// single quotes, comments, blank lines and lines without `=`
+		{"quoting", "coreos", "1967.6.0"},
+	}
+	for _, test := range tests {
+		id, version, err := readOSRelease("testdata/os-release/" + test.file)
+		if err != nil || id != test.id || version != test.version {
+			t.Errorf("%s: expected %q %q, got %q %q, %v", test.file, test.id, test.version, id, version, err)
+		}
+	}
+	if _, _, err := readOSRelease("testdata/os-release/missing"); !os.IsNotExist(err) {
+		t.Errorf("expected the file not to exist, got %v", err)
+	}
+}
+
+func TestConditionMet(t *testing.T) {
+	root := "testdata/root"
+	labels := map[string]string{"role": "infra", "zone": "a"}
+	node := func(file string) *nodeInfo {
+		n := &nodeInfo{labels: labels}
+		n.osID, n.osVersion, n.osErr = readOSRelease("testdata/os-release/" + file)
+		return n
+	}
+	rhel7, fedora := node("rhel7"), node("fedora")
+	unknown := node("missing")
+	unlabeled := &nodeInfo{osID: "rhel", osVersion: "7.6"}
+
+	tests := []struct {
+		cond *Condition
+		node *nodeInfo
+		// err is a part of the error expected, empty if the condition is met
+		err string
+	}{
+		{&Condition{OS: []string{"^rhel$"}}, rhel7, ""},
+		{&Condition{OS: []string{"^centos$", "^rhel$"}}, rhel7, ""},
+		{&Condition{OS: []string{"^rhel$"}}, fedora, `os "fedora" does not match`},
+		// patterns are not anchored unless they say so
+		{&Condition{OS: []string{"rh"}}, rhel7, ""},
+		{&Condition{OSVersion: []string{`^7\.`}}, rhel7, ""},
+		{&Condition{OSVersion: []string{`^7\.`}}, fedora, `os version "29" does not match`},
+		{&Condition{OS: []string{"^rhel$"}, OSVersion: []string{`^8\.`}}, rhel7, `os version "7.6" does not match`},
+		{&Condition{OS: []string{"^fedora$"}, OSVersion: []string{"^2[89]$"}}, fedora, ""},
+		{&Condition{OS: []string{"^rhel$"}}, unknown, "unable to read os-release"},
+		// without os patterns the os-release is not needed
+		{&Condition{NodeLabels: map[string]string{"role": "infra"}}, unknown, ""},
+
+		{&Condition{NodeLabels: map[string]string{"role": "infra", "zone": ""}}, rhel7, ""},
+		{&Condition{NodeLabels: map[string]string{"role": "compute"}}, rhel7, `node label role is "infra" not "compute"`},
+		{&Condition{NodeLabels: map[string]string{"gpu": ""}}, rhel7, "node label not set: gpu"},
+		{&Condition{NodeLabels: map[string]string{"role": ""}}, unlabeled, "node labels are unknown"},
+
+		{&Condition{Binaries: []string{"tool"}}, rhel7, ""},
+		{&Condition{Binaries: []string{"/usr/bin/tool", "linked"}}, rhel7, ""},
+		{&Condition{Binaries: []string{"data"}}, rhel7, "binary not found: data"},
+		{&Condition{Binaries: []string{"dir"}}, rhel7, "binary not found: dir"},
+		{&Condition{Binaries: []string{"/opt/escape"}}, rhel7, "binary not found: /opt/escape"},
+		{&Condition{Binaries: []string{"tool", "missing"}}, rhel7, "binary not found: missing"},
+	}
+	for _, test := range tests {
+		if err := test.cond.build(); err != nil {
+			t.Fatalf("%+v: unable to build: %v", test.cond, err)
+		}
+		err := test.cond.met(test.node, root)
+		switch {
+		case test.err == "" && err != nil:
+			t.Errorf("%+v: unexpected error: %v", test.cond, err)
+		case test.err != "" && err == nil:
+			t.Errorf("%+v: expected error %q, got none", test.cond, test.err)
+		case test.err != "" && !strings.Contains(err.Error(), test.err):
+			t.Errorf("%+v: expected error %q, got: %v", test.cond, test.err, err)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package command
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/eparis/admin-rpc/operations/util"
+)
+
+// Limits are the resources a command, and everything it starts, may use. The
+// command runs in a cgroup of its own so they apply however many namespaces
+// of the host it enters. Unset limits are not enforced.
+type Limits struct {
+	// CPU is how much CPU time the command gets, as a Kubernetes quantity,
+	// e.g. "100m" or "0.5", at least "10m"
+	CPU string `json:"cpu,omitempty" yaml:"cpu,omitempty"`
+	// Memory is the most memory the command may use, as a Kubernetes
+	// quantity, e.g. "64Mi". The command is killed if it needs more.
+	Memory string `json:"memory,omitempty" yaml:"memory,omitempty"`
+	// Pids is the most processes and threads the command may have. The
+	// command is killed if it tries to start more. The helper which runs
+	// the command is not counted, only the command once executed.
+	Pids int64 `json:"pids,omitempty" yaml:"pids,omitempty"`
+	// IOWeight is the share of disk IO the command gets when the disk is
+	// busy, from 1 to 10000. Everything else on the node has 100.
+	IOWeight uint64 `json:"ioWeight,omitempty" yaml:"ioWeight,omitempty"`
+}
+
+func (exec *Exec) buildLimits() error {
+	if exec.MaxOutputBytes < 0 {
+		return fmt.Errorf("maxOutputBytes for %s must not be negative: %d", exec.CmdName, exec.MaxOutputBytes)
+	}
+	if exec.OutputBytesPerSecond < 0 {
+		return fmt.Errorf("outputBytesPerSecond for %s must not be negative: %d", exec.CmdName, exec.OutputBytesPerSecond)
+	}
+	if exec.Limits == nil {
+		return nil
+	}
+	in := exec.Limits
+	limits := &util.Limits{
+		Pids:     in.Pids,
+		IOWeight: in.IOWeight,
+	}
+	if in.CPU != "" {
+		q, err := resource.ParseQuantity(in.CPU)
+		if err != nil {
+			return fmt.Errorf("limits for %s: invalid cpu: %v", exec.CmdName, err)
+		}
+		if limits.CPUMillis = q.MilliValue(); limits.CPUMillis < util.MinCPUMillis {
+			return fmt.Errorf("limits for %s: cpu must be at least %dm: %s", exec.CmdName, util.MinCPUMillis, in.CPU)
+		}
+	}
+	if in.Memory != "" {
+		q, err := resource.ParseQuantity(in.Memory)
+		if err != nil {
+			return fmt.Errorf("limits for %s: invalid memory: %v", exec.CmdName, err)
+		}
+		if limits.MemoryBytes = q.Value(); limits.MemoryBytes <= 0 {
+			return fmt.Errorf("limits for %s: memory must be more than 0: %s", exec.CmdName, in.Memory)
+		}
+	}
+	if in.Pids < 0 {
+		return fmt.Errorf("limits for %s: pids must not be negative: %d", exec.CmdName, in.Pids)
+	}
+	if in.IOWeight != 0 && (in.IOWeight < util.MinIOWeight || in.IOWeight > util.MaxIOWeight) {
+		return fmt.Errorf("limits for %s: ioWeight must be from %d to %d: %d", exec.CmdName, util.MinIOWeight, util.MaxIOWeight, in.IOWeight)
+	}
+	exec.limits = limits
+	return nil
+}
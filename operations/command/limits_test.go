@@ -0,0 +1,39 @@
+package command
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBuildLimits(t *testing.T) {
+	tests := []struct {
+		limits *Limits
+		cpu    int64
+		// err is a part of the error expected, empty if the limits are valid
+		err string
+	}{
+		{&Limits{CPU: "100m"}, 100, ""},
+		{&Limits{CPU: "0.5"}, 500, ""},
+		{&Limits{CPU: "10m"}, 10, ""},
+		{&Limits{CPU: "9m"}, 0, "cpu must be at least 10m: 9m"},
+		{&Limits{CPU: "0"}, 0, "cpu must be at least 10m: 0"},
+		{&Limits{CPU: "lots"}, 0, "invalid cpu"},
+		{&Limits{Memory: "0"}, 0, "memory must be more than 0"},
+		{&Limits{Pids: -1}, 0, "pids must not be negative"},
+		{&Limits{IOWeight: 10001}, 0, "ioWeight must be from 1 to 10000"},
+	}
+	for _, test := range tests {
+		exec := &Exec{CmdName: "test", Limits: test.limits}
+		err := exec.buildLimits()
+		switch {
+		case test.err == "" && err != nil:
+			t.Errorf("%+v: unexpected error: %v", test.limits, err)
+		case test.err != "" && err == nil:
+			t.Errorf("%+v: expected error %q, got none", test.limits, test.err)
+		case test.err != "" && !strings.Contains(err.Error(), test.err):
+			t.Errorf("%+v: expected error %q, got: %v", test.limits, test.err, err)
+		case err == nil && exec.limits.CPUMillis != test.cpu:
+			t.Errorf("%+v: expected %dm, got %dm", test.limits, test.cpu, exec.limits.CPUMillis)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package command
+
+import (
+	"fmt"
+
+	"github.com/eparis/admin-rpc/operations/util"
+)
+
+// Where a command gets each of its namespaces from
+const (
+	NamespaceHost   = "host"
+	NamespaceSelf   = "self"
+	NamespaceTarget = "target"
+)
+
+// namespaceNames are the namespaces which may be listed in namespaces, in the
+// order they are reported. user and cgroup are not entered unless asked for as
+// older kernels do not support them.
+var namespaceNames = []string{"mount", "uts", "ipc", "net", "pid", "user", "cgroup"}
+
+var namespaceDefaults = map[string]string{
+	"mount":  NamespaceHost,
+	"uts":    NamespaceHost,
+	"ipc":    NamespaceHost,
+	"net":    NamespaceHost,
+	"pid":    NamespaceHost,
+	"user":   NamespaceSelf,
+	"cgroup": NamespaceSelf,
+}
+
+// setNamespace sets the pid whose namespace called name is entered. The root
+// and working directory follow the mount namespace.
+func setNamespace(ns *util.Namespaces, name string, pid int) {
+	switch name {
+	case "mount":
+		ns.Mount = pid
+		ns.Root = pid
+		ns.Cwd = pid
+	case "uts":
+		ns.Uts = pid
+	case "ipc":
+		ns.IPC = pid
+	case "net":
+		ns.Net = pid
+	case "pid":
+		ns.Pid = pid
+	case "user":
+		ns.User = pid
+	case "cgroup":
+		ns.Cgroup = pid
+	}
+}
+
+// buildNamespaces works out the namespaces the command runs in. Namespaces
+// of the target are only known once a request names the target.
+func (exec *Exec) buildNamespaces() error {
+	for name, from := range exec.Namespaces {
+		if _, ok := namespaceDefaults[name]; !ok {
+			return fmt.Errorf("namespaces for %s: unknown namespace: %s", exec.CmdName, name)
+		}
+		if from != NamespaceHost && from != NamespaceSelf && from != NamespaceTarget {
+			return fmt.Errorf("namespaces for %s: %s must be host, self or target: %s", exec.CmdName, name, from)
+		}
+	}
+	exec.namespaces = util.Namespaces{}
+	exec.hostNamespaces = nil
+	exec.targetNamespaces = nil
+	for _, name := range namespaceNames {
+		from, ok := exec.Namespaces[name]
+		if !ok {
+			from = namespaceDefaults[name]
+		}
+		switch from {
+		case NamespaceHost:
+			setNamespace(&exec.namespaces, name, 1)
+			exec.hostNamespaces = append(exec.hostNamespaces, name)
+		case NamespaceTarget:
+			exec.targetNamespaces = append(exec.targetNamespaces, name)
+		}
+	}
+	return nil
+}
+
+// namespacesFor returns the namespaces to run the command in for a request
+// with the target, which may be nil
+func (exec *Exec) namespacesFor(t *target) util.Namespaces {
+	ns := exec.namespaces
+	if t != nil {
+		for _, name := range exec.targetNamespaces {
+			setNamespace(&ns, name, t.pid)
+		}
+	}
+	return ns
+}
+
+// root is where the server sees the root of the mount namespace the command
+// runs in, paths and binaries are checked under it. Without a target the
+// host stands in for it.
+func (exec *Exec) root(t *target) string {
+	from, ok := exec.Namespaces["mount"]
+	if !ok {
+		from = namespaceDefaults["mount"]
+	}
+	switch {
+	case from == NamespaceSelf:
+		return "/"
+	case from == NamespaceTarget && t != nil:
+		return fmt.Sprintf("%s/%d/root", procDir, t.pid)
+	}
+	return hostRoot
+}
@@ -3,16 +3,83 @@ package command
 import (
 	"fmt"
 	"strings"
+
+	rpcapi "github.com/eparis/admin-rpc/api"
 )
 
 type parseOp struct {
-	exec       *Exec
+	// rule is the command or subcommand the arguments are checked against
+	rule *ArgRule
+	// auth is the auth of the deepest subcommand which set one
+	auth       *ExecAuth
 	foundFlags []string
+	// args are the arguments which have not been parsed yet
+	args []string
+	// nouns are collected to be checked against the positionals of the rule
+	// and for the templates of the auth
+	nouns []string
+	// root is where the mount namespace of the command is seen, to check paths
+	root string
+	// nounSeen is set once the rule has consumed a noun, after which nouns
+	// are no longer considered as subcommands
+	nounSeen bool
+	// explain records the result of every check instead of stopping at the
+	// first failure
+	explain bool
+	checks  []*rpcapi.CheckResult
+	failed  bool
+}
+
+// record notes the result of a check. When explaining it always returns nil
+// so that the remaining arguments are still checked.
+func (po *parseOp) record(kind rpcapi.CheckResult_Kind, arg string, err error) error {
+	if !po.explain {
+		return err
+	}
+	res := &rpcapi.CheckResult{
+		Kind:   kind,
+		Arg:    arg,
+		Passed: err == nil,
+	}
+	if err != nil {
+		res.Message = err.Error()
+		po.failed = true
+	}
+	po.checks = append(po.checks, res)
+	return nil
+}
+
+// checkDenied refuses flags in deniedFlags and, if the flag has a value,
+// values matching deniedFlagValues
+func (po *parseOp) checkDenied(flag, value string, hasValue bool) error {
+	for _, denied := range po.rule.DeniedFlags {
+		if denied == flag {
+			return fmt.Errorf("Flag denied by rule %q: %s", denied, flagString(flag))
+		}
+	}
+	if !hasValue {
+		return nil
+	}
+	if re := po.rule.deniedFlagValue[flag].match(value); re != nil {
+		return fmt.Errorf("Flag value denied by rule %q: %s %s", re.String(), flagString(flag), value)
+	}
+	return nil
+}
+
+// checkDeniedNoun refuses nouns matching deniedNouns
+func (po *parseOp) checkDeniedNoun(noun string) error {
+	if re := po.rule.deniedNoun.match(noun); re != nil {
+		return fmt.Errorf("Noun denied by rule %q: %s", re.String(), noun)
+	}
+	return nil
 }
 
 func (po *parseOp) checkShort(flag string) error {
 	po.foundFlags = append(po.foundFlags, flag)
-	for _, short := range po.exec.PermittedShort {
+	if err := po.checkDenied(flag, "", false); err != nil {
+		return err
+	}
+	for _, short := range po.rule.PermittedShort {
 		if flag == short {
 			return nil
 		}
@@ -20,9 +87,12 @@ func (po *parseOp) checkShort(flag string) error {
 	return fmt.Errorf("Shorthand flag not permitted: -%s", flag)
 }
 
-func (po *parseOp) checkLongVal(flag, value string) error {
+func (po *parseOp) checkLongVal(flag, value string, hasValue bool) error {
 	po.foundFlags = append(po.foundFlags, flag)
-	regs, ok := po.exec.permittedLong[flag]
+	if err := po.checkDenied(flag, value, hasValue); err != nil {
+		return err
+	}
+	regs, ok := po.rule.permittedLong[flag]
 	if !ok {
 		return fmt.Errorf("Long flag not permitted: --%s", flag)
 	}
@@ -35,32 +105,99 @@ func (po *parseOp) checkLongVal(flag, value string) error {
 	return fmt.Errorf("Flag value not permitted: --%s=%s", flag, value)
 }
 
+// checkFlagValue checks a flag listed in flagArgs and its value, if any
+func (po *parseOp) checkFlagValue(flag string, fa *FlagArg, value string, hasValue bool) error {
+	po.foundFlags = append(po.foundFlags, flag)
+	if err := po.checkDenied(flag, value, hasValue); err != nil {
+		return err
+	}
+	if !hasValue {
+		if fa.Arg == FlagArgRequired {
+			return fmt.Errorf("Flag requires a value: %s", flagString(flag))
+		}
+		return nil
+	}
+	if fa.Arg == FlagArgNone {
+		return fmt.Errorf("Flag does not take a value: %s", flagString(flag))
+	}
+	if len(fa.values) > 0 && !fa.values.valid(value) {
+		return fmt.Errorf("Flag value not permitted: %s %s", flagString(flag), value)
+	}
+	if fa.Path != nil {
+		return fa.Path.check(po.root, value)
+	}
+	return nil
+}
+
+// takeValue consumes the next argument as the value of flag, the way getopt
+// does, even if it starts with a `-`
+func (po *parseOp) takeValue(flag string) (string, error) {
+	if len(po.args) == 0 {
+		return "", fmt.Errorf("Flag requires a value: %s", flagString(flag))
+	}
+	value := po.args[0]
+	po.args = po.args[1:]
+	return value, nil
+}
+
 func (po *parseOp) checkNoun(noun string) error {
-	if po.exec.permittedNoun.valid(noun) {
+	if err := po.checkDeniedNoun(noun); err != nil {
+		return err
+	}
+	if po.rule.permittedNoun.valid(noun) {
 		return nil
 	}
 	return fmt.Errorf("Noun not permitted: %s", noun)
 }
 
-func (po *parseOp) checkRequiredFlags() error {
-	for _, reqFlag := range po.exec.Required {
-		found := false
-		for _, foundFlag := range po.foundFlags {
-			if foundFlag == reqFlag {
-				found = true
-				break
-			}
+func (po *parseOp) checkRequiredFlag(reqFlag string) error {
+	for _, foundFlag := range po.foundFlags {
+		if foundFlag == reqFlag {
+			return nil
 		}
-		if !found {
-			if len(reqFlag) == 1 {
-				return fmt.Errorf("Required flag not found: -%s", reqFlag)
-			}
-			return fmt.Errorf("Required flag not found: --%s", reqFlag)
+	}
+	return fmt.Errorf("Required flag not found: %s", flagString(reqFlag))
+}
+
+func (po *parseOp) checkRequiredFlags() error {
+	for _, reqFlag := range po.rule.Required {
+		if err := po.record(rpcapi.CheckResult_REQUIRED_FLAG, flagString(reqFlag), po.checkRequiredFlag(reqFlag)); err != nil {
+			return err
 		}
 	}
 	return nil
 }
 
+// flagString adds the dashes back to a flag name
+func flagString(flag string) string {
+	if len(flag) == 1 {
+		return "-" + flag
+	}
+	return "--" + flag
+}
+
+// enterSubcommand checks the flags required by the current rule and then
+// checks all later arguments against the subcommand. It returns false if noun
+// is not a subcommand of the current rule.
+func (po *parseOp) enterSubcommand(noun string) (bool, error) {
+	if po.nounSeen {
+		return false, nil
+	}
+	sub, ok := po.rule.Subcommands[noun]
+	if !ok {
+		return false, nil
+	}
+	if err := po.checkRequiredFlags(); err != nil {
+		return true, err
+	}
+	po.rule = &sub.ArgRule
+	if sub.Auth != nil {
+		po.auth = sub.Auth
+	}
+	po.foundFlags = []string{}
+	return true, po.record(rpcapi.CheckResult_SUBCOMMAND, noun, nil)
+}
+
 func (po *parseOp) parseLongArg(s string) error {
 	name := s[2:]
 	// This is a special case where someone used `--` as an argument. Usually
@@ -68,12 +205,12 @@ func (po *parseOp) parseLongArg(s string) error {
 	// but we don't allow pipelines like that. So I'm just going to deny
 	// all cases of `--`. This maybe should change someday?
 	if len(name) == 0 {
-		return fmt.Errorf("Argument is invalid: %s", "--")
+		return po.record(rpcapi.CheckResult_SYNTAX, s, fmt.Errorf("Argument is invalid: %s", "--"))
 	}
 
 	// Check for `---` or `--=` both are bad...
 	if name[0] == '-' || name[0] == '=' {
-		return fmt.Errorf("bad arg syntax: %s", s)
+		return po.record(rpcapi.CheckResult_SYNTAX, s, fmt.Errorf("bad arg syntax: %s", s))
 	}
 
 	split := strings.SplitN(name, "=", 2)
@@ -81,52 +218,114 @@ func (po *parseOp) parseLongArg(s string) error {
 	flag := split[0]
 
 	var value string
-	if len(split) == 2 {
+	hasValue := len(split) == 2
+	if hasValue {
 		// '--flag=arg'
 		value = split[1]
 	}
 
-	return po.checkLongVal(flag, value)
+	if fa, ok := po.rule.FlagArgs[flag]; ok {
+		arg := s
+		if !hasValue && fa.Arg == FlagArgRequired {
+			// '--flag arg'
+			v, err := po.takeValue(flag)
+			if err != nil {
+				return po.record(rpcapi.CheckResult_LONG_FLAG, arg, err)
+			}
+			value, hasValue = v, true
+			arg = s + " " + v
+		}
+		return po.record(rpcapi.CheckResult_LONG_FLAG, arg, po.checkFlagValue(flag, fa, value, hasValue))
+	}
+
+	return po.record(rpcapi.CheckResult_LONG_FLAG, s, po.checkLongVal(flag, value, hasValue))
 }
 
 // "shorthands" can be a series of shorthand letters of flags (e.g. "-vvv").
-// short arguments can not take values. It is an error is there is an "="
-// short arguments will always be parsed as since flags. So all of these may
-// fail....
+// Unless a flag is listed in flagArgs it can not take a value. It is an error
+// if there is an "=" in place of a flag. So for a flag "f" which takes no
+// value all of these may fail....
 // -f=FILENAME  # Fail because of =
 // -fFILENAME   # parsed as series of flags: f, F, I, L, E, ...
 // -f FILENAME  # parsed as 1 short flags "f" and 1 noun: "FILENAME"
+// If "f" requires a value both `-fFILENAME` and `-f FILENAME` give "f" the
+// value "FILENAME". If the value is optional only `-fFILENAME` does.
 func (po *parseOp) parseShortArgs(s string) error {
 	shorthands := s[1:]
 	if len(shorthands) == 0 {
-		return fmt.Errorf("bad arg syntax: %s", s)
-	}
-	if strings.Contains(shorthands, "=") {
-		return fmt.Errorf("Setting values not permitted with short flags: %s", s)
+		return po.record(rpcapi.CheckResult_SYNTAX, s, fmt.Errorf("bad arg syntax: %s", s))
 	}
 
 	for len(shorthands) > 0 {
 		flag := shorthands[:1]
 		shorthands = shorthands[1:]
 
-		if err := po.checkShort(flag); err != nil {
+		if flag == "=" {
+			return po.record(rpcapi.CheckResult_SYNTAX, s, fmt.Errorf("Setting values not permitted with short flags: %s", s))
+		}
+
+		if fa, ok := po.rule.FlagArgs[flag]; ok && fa.Arg == FlagArgNone {
+			// Takes no value, the rest of the shorthands are more flags
+			if err := po.record(rpcapi.CheckResult_SHORT_FLAG, "-"+flag, po.checkFlagValue(flag, fa, "", false)); err != nil {
+				return err
+			}
+			continue
+		}
+		if fa, ok := po.rule.FlagArgs[flag]; ok {
+			// The rest of the shorthands, if any, are the value
+			value, hasValue := shorthands, shorthands != ""
+			shorthands = ""
+			arg := "-" + flag + value
+			if !hasValue && fa.Arg == FlagArgRequired {
+				v, err := po.takeValue(flag)
+				if err != nil {
+					return po.record(rpcapi.CheckResult_SHORT_FLAG, arg, err)
+				}
+				value, hasValue = v, true
+				arg = "-" + flag + " " + v
+			}
+			if err := po.record(rpcapi.CheckResult_SHORT_FLAG, arg, po.checkFlagValue(flag, fa, value, hasValue)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := po.record(rpcapi.CheckResult_SHORT_FLAG, "-"+flag, po.checkShort(flag)); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-func (exec *Exec) valid(cmdName string, cmdArgs []string) error {
-	po := parseOp{
-		exec:       exec,
-		foundFlags: []string{},
-	}
+func (po *parseOp) parse(cmdArgs []string) error {
 	var err error
-	for len(cmdArgs) > 0 {
-		s := cmdArgs[0]
-		cmdArgs = cmdArgs[1:]
+	po.args = cmdArgs
+	for len(po.args) > 0 {
+		s := po.args[0]
+		po.args = po.args[1:]
 		if len(s) == 0 || s[0] != '-' || len(s) == 1 {
-			if err := po.checkNoun(s); err != nil {
+			// Denied nouns win even over the name of a subcommand
+			if err := po.checkDeniedNoun(s); err != nil {
+				po.nounSeen = true
+				po.nouns = append(po.nouns, s)
+				if err := po.record(rpcapi.CheckResult_NOUN, s, err); err != nil {
+					return err
+				}
+				continue
+			}
+			entered, err := po.enterSubcommand(s)
+			if err != nil {
+				return err
+			}
+			if entered {
+				continue
+			}
+			po.nounSeen = true
+			po.nouns = append(po.nouns, s)
+			if len(po.rule.Positionals) > 0 {
+				continue
+			}
+			if err := po.record(rpcapi.CheckResult_NOUN, s, po.checkNoun(s)); err != nil {
 				return err
 			}
 			continue
@@ -141,5 +340,78 @@ func (exec *Exec) valid(cmdName string, cmdArgs []string) error {
 			return err
 		}
 	}
+	if len(po.rule.Positionals) > 0 {
+		err := po.rule.checkPositionals(po.root, po.nouns)
+		if err := po.record(rpcapi.CheckResult_NOUN, strings.Join(po.nouns, " "), err); err != nil {
+			return err
+		}
+	}
 	return po.checkRequiredFlags()
 }
+
+// checkLimits enforces the maxArgs and maxArgLength of the command
+func (po *parseOp) checkLimits(exec *Exec, cmdArgs []string) error {
+	if exec.MaxArgs > 0 && len(cmdArgs) > exec.MaxArgs {
+		err := fmt.Errorf("Too many arguments: got %d, at most %d permitted", len(cmdArgs), exec.MaxArgs)
+		if err := po.record(rpcapi.CheckResult_SYNTAX, fmt.Sprintf("%d arguments", len(cmdArgs)), err); err != nil {
+			return err
+		}
+	}
+	if exec.MaxArgLength > 0 {
+		for i, arg := range cmdArgs {
+			if len(arg) <= exec.MaxArgLength {
+				continue
+			}
+			err := fmt.Errorf("Argument %d too long: %d characters, at most %d permitted", i+1, len(arg), exec.MaxArgLength)
+			if err := po.record(rpcapi.CheckResult_SYNTAX, fmt.Sprintf("argument %d", i+1), err); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// valid checks the arguments against the command and returns the auth which
+// must also pass before the command may be run, along with the nouns its
+// templates may refer to.
+func (exec *Exec) valid(cmdName string, cmdArgs []string, t *target) (*ExecAuth, []string, error) {
+	po := parseOp{
+		rule:       &exec.ArgRule,
+		auth:       &exec.Auth,
+		foundFlags: []string{},
+		root:       exec.root(t),
+	}
+	if err := exec.checkTarget(t); err != nil {
+		return nil, nil, err
+	}
+	if err := po.checkLimits(exec, cmdArgs); err != nil {
+		return nil, nil, err
+	}
+	if err := po.parse(cmdArgs); err != nil {
+		return nil, nil, err
+	}
+	return po.auth, po.nouns, nil
+}
+
+// explain checks every argument against the rule, rather than stopping at
+// the first failure, and returns the result of each check, the auth which
+// applies to the request and the nouns its templates may refer to.
+func (exec *Exec) explain(cmdArgs []string, t *target) ([]*rpcapi.CheckResult, *ExecAuth, []string, bool) {
+	po := parseOp{
+		rule:       &exec.ArgRule,
+		auth:       &exec.Auth,
+		foundFlags: []string{},
+		root:       exec.root(t),
+		explain:    true,
+	}
+	if t != nil || len(exec.targetNamespaces) > 0 {
+		name := "<none>"
+		if t != nil {
+			name = t.String()
+		}
+		po.record(rpcapi.CheckResult_TARGET, name, exec.checkTarget(t))
+	}
+	po.checkLimits(exec, cmdArgs)
+	po.parse(cmdArgs)
+	return po.checks, po.auth, po.nouns, !po.failed
+}
@@ -0,0 +1,133 @@
+package command
+
+import (
+	"strings"
+	"testing"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// newTestExec loads a command from its yaml the way NewExec does
+func newTestExec(t *testing.T, config string) *Exec {
+	exec := &Exec{}
+	if err := yaml.Unmarshal([]byte(config), exec); err != nil {
+		t.Fatalf("Unable to parse config: %v", err)
+	}
+	if err := initExecConfig(exec); err != nil {
+		t.Fatalf("Unable to load config: %v", err)
+	}
+	return exec
+}
+
+const parseTestConfig = `
+cmdName: test
+auth:
+  verb: get
+  resource: pods
+permittedShortFlags:
+- v
+- q
+permittedLongFlags:
+  all:
+flagArgs:
+  c:
+    values:
+    - "^[0-9]+$"
+  table:
+    values:
+    - "^(nat|filter)$"
+  l:
+    arg: optional
+    values:
+    - "^[1-5]$"
+  level:
+    arg: optional
+    values:
+    - "^[1-5]$"
+  n:
+    arg: none
+permittedNouns:
+- "^hello$"
+- "^bad$"
+deniedFlags:
+- q
+deniedNouns:
+- "^bad$"
+subcommands:
+  bad:
+  get:
+    permittedNouns:
+    - "^pods$"
+`
+
+func TestParseArgs(t *testing.T) {
+	exec := newTestExec(t, parseTestConfig)
+	tests := []struct {
+		args []string
+		// err is a part of the error expected, empty if the args are valid
+		err string
+	}{
+		{[]string{}, ""},
+		{[]string{"-v"}, ""},
+		{[]string{"-x"}, "Shorthand flag not permitted: -x"},
+		{[]string{"--all"}, ""},
+		{[]string{"--"}, "Argument is invalid"},
+		{[]string{"-v", "-q"}, "Flag denied"},
+
+		// required values, attached or the next argument
+		{[]string{"-c", "5"}, ""},
+		{[]string{"-c5"}, ""},
+		{[]string{"-vc5"}, ""},
+		{[]string{"-vc", "5"}, ""},
+		{[]string{"-c", "x"}, "Flag value not permitted: -c x"},
+		{[]string{"-c", "-5"}, "Flag value not permitted: -c -5"},
+		{[]string{"-c"}, "Flag requires a value: -c"},
+		{[]string{"-c5v"}, "Flag value not permitted: -c 5v"},
+		{[]string{"--table", "nat"}, ""},
+		{[]string{"--table=nat"}, ""},
+		{[]string{"--table=raw"}, "Flag value not permitted: --table raw"},
+		{[]string{"--table"}, "Flag requires a value: --table"},
+
+		// optional values must be attached
+		{[]string{"-l"}, ""},
+		{[]string{"-l3"}, ""},
+		{[]string{"-l9"}, "Flag value not permitted: -l 9"},
+		{[]string{"-l", "3"}, "Noun not permitted: 3"},
+		{[]string{"--level"}, ""},
+		{[]string{"--level=3"}, ""},
+		{[]string{"--level", "3"}, "Noun not permitted: 3"},
+
+		// flags without a value do not take the rest of the cluster
+		{[]string{"-n"}, ""},
+		{[]string{"-nv"}, ""},
+		{[]string{"-vn"}, ""},
+		{[]string{"-nvc5"}, ""},
+		{[]string{"-n5"}, "Shorthand flag not permitted: -5"},
+		{[]string{"-nq"}, "Flag denied"},
+
+		// nouns and subcommands
+		{[]string{"hello"}, ""},
+		{[]string{"goodbye"}, "Noun not permitted: goodbye"},
+		{[]string{"bad"}, "Noun denied"},
+		{[]string{"get", "pods"}, ""},
+		{[]string{"get", "hello"}, "Noun not permitted: hello"},
+		{[]string{"hello", "get"}, "Noun not permitted: get"},
+	}
+	for _, test := range tests {
+		_, _, err := exec.valid("test", test.args, nil)
+		switch {
+		case test.err == "" && err != nil:
+			t.Errorf("%q: unexpected error: %v", test.args, err)
+		case test.err != "" && err == nil:
+			t.Errorf("%q: expected error %q, got none", test.args, test.err)
+		case test.err != "" && !strings.Contains(err.Error(), test.err):
+			t.Errorf("%q: expected error %q, got: %v", test.args, test.err, err)
+		}
+
+		// explain must agree with valid
+		_, _, _, allowed := exec.explain(test.args, nil)
+		if allowed != (test.err == "") {
+			t.Errorf("%q: explain allowed %t, valid returned %v", test.args, allowed, err)
+		}
+	}
+}
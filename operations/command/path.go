@@ -0,0 +1,164 @@
+package command
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	rpcapi "github.com/eparis/admin-rpc/api"
+)
+
+const (
+	// hostRoot is where the server sees the root of the host's mount
+	// namespace
+	hostRoot = "/proc/1/root"
+	// maxSymlinks matches the limit the kernel puts on path resolution
+	maxSymlinks = 40
+)
+
+// PathRule restricts an argument to files under some directories. The path
+// must be absolute and must not contain `..`. Symlinks are followed inside
+// the mount namespace the command runs in and the path they resolve to must
+// be under one of Allow and not under any of Deny.
+//
+// The path is checked before the command runs, so a symlink changed in
+// between is not caught. Commands which follow symlinks while walking a
+// directory, like `ls -RL`, are also not confined.
+type PathRule struct {
+	Allow []string `json:"allow" yaml:"allow"`
+	Deny  []string `json:"deny,omitempty" yaml:"deny,omitempty"`
+}
+
+func (pr *PathRule) build() error {
+	if len(pr.Allow) == 0 {
+		return fmt.Errorf("path: must list the directories allowed")
+	}
+	for _, dir := range append(append([]string{}, pr.Allow...), pr.Deny...) {
+		if !filepath.IsAbs(dir) || filepath.Clean(dir) != dir {
+			return fmt.Errorf("path: directories must be absolute and clean: %s", dir)
+		}
+	}
+	return nil
+}
+
+// info describes the rule for ListCommands
+func (pr *PathRule) info() *rpcapi.PathRule {
+	if pr == nil {
+		return nil
+	}
+	return &rpcapi.PathRule{
+		Allow: pr.Allow,
+		Deny:  pr.Deny,
+	}
+}
+
+// underDir reports if path is dir or is inside it
+func underDir(path, dir string) bool {
+	return dir == "/" || path == dir || strings.HasPrefix(path, dir+"/")
+}
+
+// procMagic reports if path is one of the /proc links which point into other
+// mount namespaces, and so can not be followed by name.
+func procMagic(path string) bool {
+	if !underDir(path, "/proc") {
+		return false
+	}
+	for _, comp := range strings.Split(path, "/")[2:] {
+		switch comp {
+		case "root", "cwd", "exe", "fd", "map_files":
+			return true
+		}
+	}
+	return false
+}
+
+// resolvePath follows every symlink in path the way the kernel would if root
+// were `/`, without ever leaving root. Once a component does not exist the
+// rest of the path is joined as is.
+func resolvePath(root, path string) (string, error) {
+	resolved := "/"
+	remaining := path
+	links := 0
+	for remaining != "" {
+		comp := remaining
+		remaining = ""
+		if i := strings.IndexByte(comp, '/'); i >= 0 {
+			comp, remaining = comp[:i], comp[i+1:]
+		}
+		switch comp {
+		case "", ".":
+			continue
+		case "..":
+			resolved = filepath.Dir(resolved)
+			continue
+		}
+
+		next := filepath.Join(resolved, comp)
+		if procMagic(next) {
+			return "", fmt.Errorf("Path through /proc links not permitted: %s", path)
+		}
+		fi, err := os.Lstat(filepath.Join(root, next))
+		if os.IsNotExist(err) {
+			// The rest of the path could still name a /proc link once
+			// it exists
+			rest := filepath.Join(next, remaining)
+			if procMagic(rest) {
+				return "", fmt.Errorf("Path through /proc links not permitted: %s", path)
+			}
+			return rest, nil
+		}
+		if err != nil {
+			return "", err
+		}
+		if fi.Mode()&os.ModeSymlink == 0 {
+			resolved = next
+			continue
+		}
+
+		links++
+		if links > maxSymlinks {
+			return "", fmt.Errorf("Too many levels of symbolic links: %s", path)
+		}
+		target, err := os.Readlink(filepath.Join(root, next))
+		if err != nil {
+			return "", err
+		}
+		if filepath.IsAbs(target) {
+			resolved = "/"
+		}
+		remaining = target + "/" + remaining
+	}
+	return resolved, nil
+}
+
+// check resolves path inside root and checks it against the rule
+func (pr *PathRule) check(root, path string) error {
+	if !filepath.IsAbs(path) {
+		return fmt.Errorf("Path must be absolute: %s", path)
+	}
+	for _, comp := range strings.Split(path, "/") {
+		if comp == ".." {
+			return fmt.Errorf("Path may not contain ..: %s", path)
+		}
+	}
+	resolved, err := resolvePath(root, path)
+	if err != nil {
+		return err
+	}
+	name := path
+	if resolved != filepath.Clean(path) {
+		name = fmt.Sprintf("%s (resolves to %s)", path, resolved)
+	}
+	for _, dir := range pr.Deny {
+		if underDir(resolved, dir) {
+			return fmt.Errorf("Path not permitted: %s", name)
+		}
+	}
+	for _, dir := range pr.Allow {
+		if underDir(resolved, dir) {
+			return nil
+		}
+	}
+	return fmt.Errorf("Path not permitted: %s", name)
+}
@@ -0,0 +1,116 @@
+package command
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newTestRoot builds a tree standing in for the root of a mount namespace
+func newTestRoot(t *testing.T) string {
+	root, err := ioutil.TempDir("", "path-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, dir := range []string{"/var/log/sub", "/etc", "/proc/self", "/proc/1"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, file := range []string{"/var/log/messages", "/var/log/secure", "/var/log/sub/file", "/etc/shadow"} {
+		if err := ioutil.WriteFile(filepath.Join(root, file), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	links := map[string]string{
+		"/var/log/escape":   "/etc/shadow",
+		"/var/log/relative": "../../etc/shadow",
+		"/var/log/inside":   "sub/file",
+		"/var/log/dir":      "/var/log",
+		"/var/log/loop":     "loop",
+		"/var/log/proc":     "/proc/self/root/etc/shadow",
+		"/var/log/updir":    "../../../../../../etc",
+	}
+	for link, target := range links {
+		if err := os.Symlink(target, filepath.Join(root, link)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestPathRuleCheck(t *testing.T) {
+	root := newTestRoot(t)
+	defer os.RemoveAll(root)
+
+	logs := &PathRule{Allow: []string{"/var/log"}, Deny: []string{"/var/log/secure"}}
+	everything := &PathRule{Allow: []string{"/"}}
+	tests := []struct {
+		rule *PathRule
+		path string
+		// err is a part of the error expected, empty if the path is valid
+		err string
+	}{
+		{logs, "/var/log/messages", ""},
+		{logs, "/var/log/sub/file", ""},
+		{logs, "/var/log/inside", ""},
+		{logs, "/var/log//./messages", ""},
+		{logs, "/var/log/missing/file", ""},
+		{logs, "var/log/messages", "Path must be absolute"},
+		{logs, "/etc/shadow", "Path not permitted: /etc/shadow"},
+
+		// traversal
+		{logs, "/var/log/../../etc/shadow", "Path may not contain .."},
+		{logs, "/var/log/sub/..", "Path may not contain .."},
+
+		// symlinks escaping the allowed directories, resolved inside root
+		{logs, "/var/log/escape", "resolves to /etc/shadow"},
+		{logs, "/var/log/relative", "resolves to /etc/shadow"},
+		{logs, "/var/log/updir/shadow", "resolves to /etc/shadow"},
+		{logs, "/var/log/loop", "Too many levels of symbolic links"},
+
+		// deny wins over allow, also when reached through a symlink
+		{logs, "/var/log/secure", "Path not permitted: /var/log/secure"},
+		{logs, "/var/log/dir/secure", "resolves to /var/log/secure"},
+
+		// /proc links into other mount namespaces
+		{everything, "/proc/1/root/etc/shadow", "Path through /proc links not permitted"},
+		{everything, "/proc/self/root", "Path through /proc links not permitted"},
+		{everything, "/proc/self/fd/3", "Path through /proc links not permitted"},
+		{everything, "/proc/self/cwd/etc", "Path through /proc links not permitted"},
+		{everything, "/proc/2/map_files/x", "Path through /proc links not permitted"},
+		{everything, "/var/log/proc", "Path through /proc links not permitted"},
+	}
+	for _, test := range tests {
+		err := test.rule.check(root, test.path)
+		switch {
+		case test.err == "" && err != nil:
+			t.Errorf("%s: unexpected error: %v", test.path, err)
+		case test.err != "" && err == nil:
+			t.Errorf("%s: expected error %q, got none", test.path, test.err)
+		case test.err != "" && !strings.Contains(err.Error(), test.err):
+			t.Errorf("%s: expected error %q, got: %v", test.path, test.err, err)
+		}
+	}
+}
+
+func TestPathRuleBuild(t *testing.T) {
+	tests := []struct {
+		rule *PathRule
+		ok   bool
+	}{
+		{&PathRule{Allow: []string{"/var/log"}}, true},
+		{&PathRule{Allow: []string{"/"}, Deny: []string{"/etc"}}, true},
+		{&PathRule{}, false},
+		{&PathRule{Allow: []string{"var/log"}}, false},
+		{&PathRule{Allow: []string{"/var/log/"}}, false},
+		{&PathRule{Allow: []string{"/var/log"}, Deny: []string{"/var/../etc"}}, false},
+	}
+	for _, test := range tests {
+		if err := test.rule.build(); (err == nil) != test.ok {
+			t.Errorf("%+v: expected ok %t, got: %v", test.rule, test.ok, err)
+		}
+	}
+}
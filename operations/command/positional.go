@@ -0,0 +1,169 @@
+package command
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Positional is one slot of the non-flag arguments of a command. Slots are
+// filled in order, each taking between Min and Max arguments.
+type Positional struct {
+	// Name is only used to describe the slot in errors
+	Name   string   `json:"name" yaml:"name"`
+	Values []string `json:"values,omitempty" yaml:"values,omitempty"`
+	values argRegex
+	// Path, if set, checks every argument in the slot as a path
+	Path *PathRule `json:"path,omitempty" yaml:"path,omitempty"`
+	// Min defaults to 1
+	Min *int `json:"min,omitempty" yaml:"min,omitempty"`
+	// Max defaults to Min, or 1 if Min is 0. -1 means no limit.
+	Max *int `json:"max,omitempty" yaml:"max,omitempty"`
+	min int
+	max int
+}
+
+func (pos *Positional) build() error {
+	if pos.Name == "" {
+		return fmt.Errorf("positionals: every slot must have a name")
+	}
+	if len(pos.Values) == 0 && pos.Path == nil {
+		return fmt.Errorf("positionals %s: must list the values or path permitted", pos.Name)
+	}
+	if pos.Path != nil {
+		if err := pos.Path.build(); err != nil {
+			return fmt.Errorf("positionals %s: %v", pos.Name, err)
+		}
+	}
+	pos.min = 1
+	if pos.Min != nil {
+		pos.min = *pos.Min
+	}
+	pos.max = pos.min
+	if pos.max == 0 {
+		pos.max = 1
+	}
+	if pos.Max != nil {
+		pos.max = *pos.Max
+	}
+	if pos.min < 0 || pos.max < -1 || (pos.max != -1 && pos.max < pos.min) {
+		return fmt.Errorf("positionals %s: invalid min %d and max %d", pos.Name, pos.min, pos.max)
+	}
+	regs, err := stringsToRe(pos.Values)
+	if err != nil {
+		return err
+	}
+	pos.values = regs
+	return nil
+}
+
+// check checks a single argument against the slot
+func (pos *Positional) check(root, arg string) error {
+	if len(pos.values) > 0 && !pos.values.valid(arg) {
+		return fmt.Errorf("Not a valid %s: %s", pos.Name, arg)
+	}
+	if pos.Path != nil {
+		return pos.Path.check(root, arg)
+	}
+	return nil
+}
+
+// usage describes the slot like a man page would, e.g. `[count]` or `file...`
+func (pos *Positional) usage() string {
+	name := pos.Name
+	if pos.max != 1 {
+		name += "..."
+	}
+	if pos.min == 0 {
+		name = "[" + name + "]"
+	}
+	return name
+}
+
+func positionalUsage(slots []*Positional) string {
+	usage := make([]string, 0, len(slots))
+	for _, slot := range slots {
+		usage = append(usage, slot.usage())
+	}
+	return strings.Join(usage, " ")
+}
+
+// positionalMatcher finds if the nouns can be split across the slots. failed
+// remembers the (slot, noun) starting points already known not to match so
+// the search stays polynomial, and checked the result of checking each noun
+// against each slot, as path checks hit the filesystem.
+type positionalMatcher struct {
+	root    string
+	slots   []*Positional
+	nouns   []string
+	failed  map[[2]int]bool
+	checked map[[2]int]bool
+	// err is the first error from checking a noun against a slot
+	err error
+}
+
+func (pm *positionalMatcher) check(slot, noun int) bool {
+	key := [2]int{slot, noun}
+	if ok, found := pm.checked[key]; found {
+		return ok
+	}
+	err := pm.slots[slot].check(pm.root, pm.nouns[noun])
+	if err != nil && pm.err == nil {
+		pm.err = err
+	}
+	pm.checked[key] = err == nil
+	return err == nil
+}
+
+func (pm *positionalMatcher) match(slot, noun int) bool {
+	if slot == len(pm.slots) {
+		return noun == len(pm.nouns)
+	}
+	if pm.failed[[2]int{slot, noun}] {
+		return false
+	}
+	pos := pm.slots[slot]
+	for n := 0; noun+n <= len(pm.nouns); n++ {
+		if pos.max != -1 && n > pos.max {
+			break
+		}
+		if n > 0 && !pm.check(slot, noun+n-1) {
+			break
+		}
+		if n >= pos.min && pm.match(slot+1, noun+n) {
+			return true
+		}
+	}
+	pm.failed[[2]int{slot, noun}] = true
+	return false
+}
+
+// checkPositionals checks the non-flag arguments against the positional slots
+func (rule *ArgRule) checkPositionals(root string, nouns []string) error {
+	minNouns, maxNouns := 0, 0
+	for _, pos := range rule.Positionals {
+		minNouns += pos.min
+		if pos.max == -1 || maxNouns == -1 {
+			maxNouns = -1
+		} else {
+			maxNouns += pos.max
+		}
+	}
+	usage := positionalUsage(rule.Positionals)
+	if len(nouns) < minNouns {
+		return fmt.Errorf("Not enough arguments: got %d, expected: %s", len(nouns), usage)
+	}
+	if maxNouns != -1 && len(nouns) > maxNouns {
+		return fmt.Errorf("Too many arguments: got %d, expected: %s", len(nouns), usage)
+	}
+	pm := positionalMatcher{
+		root:    root,
+		slots:   rule.Positionals,
+		nouns:   nouns,
+		failed:  map[[2]int]bool{},
+		checked: map[[2]int]bool{},
+	}
+	if !pm.match(0, 0) {
+		return fmt.Errorf("Arguments not permitted: %s, expected: %s: %v", strings.Join(nouns, " "), usage, pm.err)
+	}
+	return nil
+}
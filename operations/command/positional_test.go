@@ -0,0 +1,153 @@
+package command
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckPositionals(t *testing.T) {
+	exec := newTestExec(t, `
+cmdName: test
+auth:
+  verb: get
+  resource: pods
+subcommands:
+  greet:
+    positionals:
+    - name: greeting
+      values:
+      - "^hello$"
+    - name: count
+      values:
+      - "^[0-9]+$"
+      min: 0
+      max: -1
+  split:
+    positionals:
+    - name: first
+      values:
+      - ".*"
+      max: 2
+    - name: last
+      values:
+      - "^x$"
+  greedy:
+    positionals:
+    - name: any
+      values:
+      - ".*"
+      min: 0
+      max: -1
+    - name: last
+      values:
+      - "^x$"
+      min: 2
+`)
+	tests := []struct {
+		rule  string
+		nouns []string
+		// err is a part of the error expected, empty if the nouns are valid
+		err string
+	}{
+		{"greet", []string{}, "Not enough arguments: got 0, expected: greeting [count...]"},
+		{"greet", []string{"hello"}, ""},
+		{"greet", []string{"hello", "5"}, ""},
+		{"greet", []string{"hello", "5", "6", "7"}, ""},
+		{"greet", []string{"5"}, "Not a valid greeting: 5"},
+		{"greet", []string{"hello", "there"}, "Not a valid count: there"},
+
+		// first takes one or two, last exactly one
+		{"split", []string{"p", "x"}, ""},
+		{"split", []string{"p", "q", "x"}, ""},
+		{"split", []string{"x", "x"}, ""},
+		{"split", []string{"p", "q", "r", "x"}, "Too many arguments: got 4, expected: first... last"},
+		{"split", []string{"p", "q"}, "Not a valid last: q"},
+
+		// any must leave two for last, however many it could take
+		{"greedy", []string{"x", "x"}, ""},
+		{"greedy", []string{"x", "x", "x", "x"}, ""},
+		{"greedy", []string{"p", "q", "x", "x"}, ""},
+		{"greedy", []string{"p", "x", "q"}, "Arguments not permitted: p x q"},
+		{"greedy", []string{"x"}, "Not enough arguments"},
+	}
+	for _, test := range tests {
+		err := exec.Subcommands[test.rule].checkPositionals("/", test.nouns)
+		switch {
+		case test.err == "" && err != nil:
+			t.Errorf("%s %q: unexpected error: %v", test.rule, test.nouns, err)
+		case test.err != "" && err == nil:
+			t.Errorf("%s %q: expected error %q, got none", test.rule, test.nouns, test.err)
+		case test.err != "" && !strings.Contains(err.Error(), test.err):
+			t.Errorf("%s %q: expected error %q, got: %v", test.rule, test.nouns, test.err, err)
+		}
+	}
+}
+
+func TestPositionalBuild(t *testing.T) {
+	one, two, none := 1, 2, -1
+	tests := []struct {
+		pos *Positional
+		min int
+		max int
+		// err is a part of the error expected, empty if the slot is valid
+		err string
+	}{
+		{&Positional{Name: "a", Values: []string{"."}}, 1, 1, ""},
+		{&Positional{Name: "a", Values: []string{"."}, Min: &two}, 2, 2, ""},
+		{&Positional{Name: "a", Values: []string{"."}, Max: &none}, 1, -1, ""},
+		{&Positional{Name: "a", Values: []string{"."}, Min: &two, Max: &one}, 0, 0, "invalid min 2 and max 1"},
+		{&Positional{Name: "a"}, 0, 0, "must list the values"},
+		{&Positional{Values: []string{"."}}, 0, 0, "every slot must have a name"},
+	}
+	for _, test := range tests {
+		err := test.pos.build()
+		switch {
+		case test.err == "" && err != nil:
+			t.Errorf("%+v: unexpected error: %v", test.pos, err)
+		case test.err != "" && err == nil:
+			t.Errorf("%+v: expected error %q, got none", test.pos, test.err)
+		case test.err != "" && !strings.Contains(err.Error(), test.err):
+			t.Errorf("%+v: expected error %q, got: %v", test.pos, test.err, err)
+		case err == nil && (test.pos.min != test.min || test.pos.max != test.max):
+			t.Errorf("%+v: expected min %d and max %d, got %d and %d", test.pos, test.min, test.max, test.pos.min, test.pos.max)
+		}
+	}
+}
+
+func TestArgLimits(t *testing.T) {
+	exec := newTestExec(t, `
+cmdName: test
+auth:
+  verb: get
+  resource: pods
+permittedShortFlags:
+- v
+permittedNouns:
+- ".*"
+maxArgs: 3
+maxArgLength: 5
+`)
+	tests := []struct {
+		args []string
+		// err is a part of the error expected, empty if the args are valid
+		err string
+	}{
+		{[]string{"a", "b", "c"}, ""},
+		{[]string{"-v", "b", "c"}, ""},
+		{[]string{"a", "b", "c", "d"}, "Too many arguments: got 4, at most 3 permitted"},
+		{[]string{"-v", "-v", "-v", "-v"}, "Too many arguments: got 4, at most 3 permitted"},
+		{[]string{"abcde"}, ""},
+		{[]string{"a", "abcdef"}, "Argument 2 too long: 6 characters, at most 5 permitted"},
+	}
+	for _, test := range tests {
+		_, _, err := exec.valid("test", test.args, nil)
+		switch {
+		case test.err == "" && err != nil:
+			t.Errorf("%q: unexpected error: %v", test.args, err)
+		case test.err != "" && err == nil:
+			t.Errorf("%q: expected error %q, got none", test.args, test.err)
+		case test.err != "" && !strings.Contains(err.Error(), test.err):
+			t.Errorf("%q: expected error %q, got: %v", test.args, test.err, err)
+		}
+	}
+}
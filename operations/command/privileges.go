@@ -0,0 +1,68 @@
+package command
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/eparis/admin-rpc/operations/nsenter"
+)
+
+// The size of a struct sock_filter and the most a filter may hold,
+// BPF_MAXINSNS
+const (
+	sockFilterSize   = 8
+	maxSeccompFilter = 4096
+)
+
+// RunAs is who a command runs as, by number, as names would depend on the
+// mount namespace the command runs in
+type RunAs struct {
+	User   *int  `json:"user" yaml:"user"`
+	Group  *int  `json:"group" yaml:"group"`
+	Groups []int `json:"groups,omitempty" yaml:"groups,omitempty"`
+}
+
+func (exec *Exec) buildPrivileges() error {
+	if exec.RunAs == nil && exec.Capabilities == nil && !exec.NoNewPrivs && exec.SeccompProfile == "" {
+		return nil
+	}
+	p := &nsenter.Privileges{
+		NoNewPrivs: exec.NoNewPrivs,
+	}
+	if exec.RunAs != nil {
+		if exec.RunAs.User == nil || exec.RunAs.Group == nil {
+			return fmt.Errorf("runAs for %s must set user and group", exec.CmdName)
+		}
+		p.RunAs = &nsenter.IDs{
+			UID:    *exec.RunAs.User,
+			GID:    *exec.RunAs.Group,
+			Groups: exec.RunAs.Groups,
+		}
+	}
+	if exec.Capabilities != nil {
+		p.Capabilities = []uint{}
+		for _, name := range exec.Capabilities {
+			cap, err := nsenter.ParseCapability(name)
+			if err != nil {
+				return fmt.Errorf("capabilities for %s: %v", exec.CmdName, err)
+			}
+			p.Capabilities = append(p.Capabilities, cap)
+		}
+	}
+	if exec.SeccompProfile != "" {
+		if !filepath.IsAbs(exec.SeccompProfile) {
+			return fmt.Errorf("seccompProfile for %s must be absolute: %s", exec.CmdName, exec.SeccompProfile)
+		}
+		filter, err := ioutil.ReadFile(exec.SeccompProfile)
+		if err != nil {
+			return fmt.Errorf("seccompProfile for %s: %v", exec.CmdName, err)
+		}
+		if len(filter) == 0 || len(filter)%sockFilterSize != 0 || len(filter)/sockFilterSize > maxSeccompFilter {
+			return fmt.Errorf("seccompProfile for %s is not a compiled filter of at most %d instructions: %s", exec.CmdName, maxSeccompFilter, exec.SeccompProfile)
+		}
+		p.Seccomp = filter
+	}
+	exec.privileges = p
+	return nil
+}
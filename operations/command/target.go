@@ -0,0 +1,288 @@
+package command
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	authzv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	rpcapi "github.com/eparis/admin-rpc/api"
+	"github.com/eparis/admin-rpc/operations/util"
+)
+
+// procDir is where the server sees the processes of the host, the daemonset
+// runs with hostPID
+var procDir = "/proc"
+
+// containerRuntimes name the cgroups of containers when systemd manages them,
+// e.g. docker-<id>.scope
+var containerRuntimes = []string{"docker", "crio", "cri-containerd", "libpod"}
+
+// minContainerIDLength is the shortest prefix of a container ID accepted,
+// the length `docker ps` shows
+const minContainerIDLength = 12
+
+// target is the container a request runs in
+type target struct {
+	namespace string
+	pod       string
+	container string
+	id        string
+	// pid is the first process of the container, its namespaces are the
+	// ones entered
+	pid int
+}
+
+func (t *target) String() string {
+	return fmt.Sprintf("%s/%s/%s", t.namespace, t.pod, t.container)
+}
+
+// trimRuntime strips the runtime from the container ID of a container
+// status, e.g. `docker://`
+func trimRuntime(id string) string {
+	if i := strings.Index(id, "://"); i >= 0 {
+		return id[i+3:]
+	}
+	return id
+}
+
+// findContainer returns the status of the container in the pod named name or,
+// if name is empty, with the ID id. With neither it returns the first
+// container of the pod.
+func findContainer(pod *corev1.Pod, name, id string) (*corev1.ContainerStatus, error) {
+	if name == "" && id == "" {
+		if len(pod.Spec.Containers) == 0 {
+			return nil, grpc.Errorf(codes.NotFound, "Pod has no containers: %s/%s", pod.Namespace, pod.Name)
+		}
+		name = pod.Spec.Containers[0].Name
+	}
+	for i := range pod.Status.ContainerStatuses {
+		status := &pod.Status.ContainerStatuses[i]
+		if name != "" && status.Name == name {
+			return status, nil
+		}
+		if name == "" && status.ContainerID != "" && strings.HasPrefix(trimRuntime(status.ContainerID), id) {
+			return status, nil
+		}
+	}
+	if name != "" {
+		return nil, grpc.Errorf(codes.NotFound, "Container not found in pod %s/%s: %s", pod.Namespace, pod.Name, name)
+	}
+	return nil, grpc.Errorf(codes.NotFound, "Container not found in pod %s/%s: %s", pod.Namespace, pod.Name, id)
+}
+
+// podForContainer finds the pod on this node running the container with the
+// ID id
+func (s *sndCmd) podForContainer(ctx context.Context, id string) (*corev1.Pod, error) {
+	if s.nodeName == "" {
+		return nil, grpc.Errorf(codes.FailedPrecondition, "Unable to find containers by ID, NODE_NAME is not set")
+	}
+	clientset := util.GetClientset(ctx)
+	pods, err := clientset.CoreV1().Pods("").List(metav1.ListOptions{FieldSelector: "spec.nodeName=" + s.nodeName})
+	if err != nil {
+		return nil, err
+	}
+	var found *corev1.Pod
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if _, err := findContainer(pod, "", id); err != nil {
+			continue
+		}
+		if found != nil {
+			return nil, grpc.Errorf(codes.InvalidArgument, "Container ID is ambiguous: %s", id)
+		}
+		found = pod
+	}
+	if found == nil {
+		return nil, grpc.Errorf(codes.NotFound, "Container not found on this node: %s", id)
+	}
+	return found, nil
+}
+
+// podExecAuthz checks the requestor may exec into the pod, the same
+// permission `kubectl exec` needs
+func podExecAuthz(ctx context.Context, namespace, pod string) error {
+	return checkAccess(ctx, &authzv1.ResourceAttributes{
+		Namespace:   namespace,
+		Verb:        "create",
+		Resource:    "pods",
+		Subresource: "exec",
+		Name:        pod,
+		Version:     "v1",
+	}, nil)
+}
+
+// resolveTarget finds the pod and container the request targets, checks the
+// requestor may exec into the pod, and finds the process whose namespaces
+// the command enters. It returns nil if the request has no target.
+func (s *sndCmd) resolveTarget(ctx context.Context, in *rpcapi.Target) (*target, error) {
+	if in == nil {
+		return nil, nil
+	}
+	id := trimRuntime(in.ContainerID)
+	if in.ContainerID != "" && len(id) < minContainerIDLength {
+		return nil, grpc.Errorf(codes.InvalidArgument, "Container ID must be at least %d characters: %s", minContainerIDLength, in.ContainerID)
+	}
+
+	var pod *corev1.Pod
+	var err error
+	switch {
+	case in.Pod != "":
+		if in.Namespace == "" {
+			return nil, grpc.Errorf(codes.InvalidArgument, "Target pod requires a namespace: %s", in.Pod)
+		}
+		// Check before looking, so nobody can probe for pods they can not see
+		if err := podExecAuthz(ctx, in.Namespace, in.Pod); err != nil {
+			return nil, err
+		}
+		clientset := util.GetClientset(ctx)
+		pod, err = clientset.CoreV1().Pods(in.Namespace).Get(in.Pod, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+	case id != "":
+		// The pod can only be authorized once it was found, so until then
+		// every failure looks the same and nobody can probe for container
+		// IDs. The real error is only in the audit log.
+		denied := grpc.Errorf(codes.PermissionDenied, "Not allowed to exec into container: %s", in.ContainerID)
+		pod, err = s.podForContainer(ctx, id)
+		if err != nil {
+			util.AddAuditData(ctx, "command.targetError", err.Error())
+			return nil, denied
+		}
+		if err := podExecAuthz(ctx, pod.Namespace, pod.Name); err != nil {
+			util.AddAuditData(ctx, "command.targetError", err.Error())
+			return nil, denied
+		}
+	default:
+		return nil, grpc.Errorf(codes.InvalidArgument, "Target must name a pod or a container ID")
+	}
+
+	if s.nodeName != "" && pod.Spec.NodeName != s.nodeName {
+		return nil, grpc.Errorf(codes.FailedPrecondition, "Pod %s/%s is on node %s, not %s", pod.Namespace, pod.Name, pod.Spec.NodeName, s.nodeName)
+	}
+	status, err := findContainer(pod, in.Container, id)
+	if err != nil {
+		return nil, err
+	}
+	if status.ContainerID == "" || status.State.Running == nil {
+		return nil, grpc.Errorf(codes.FailedPrecondition, "Container is not running in pod %s/%s: %s", pod.Namespace, pod.Name, status.Name)
+	}
+	t := &target{
+		namespace: pod.Namespace,
+		pod:       pod.Name,
+		container: status.Name,
+		id:        trimRuntime(status.ContainerID),
+	}
+	if t.pid, err = containerPid(t.id); err != nil {
+		return nil, err
+	}
+	util.AddAuditData(ctx, "command.target", t.String())
+	util.AddAuditData(ctx, "command.targetPid", strconv.Itoa(t.pid))
+	return t, nil
+}
+
+// parentPid reads the parent of the process from /proc/pid/stat. The command
+// name in the second field may contain anything, so fields are counted from
+// the last `)`.
+func parentPid(pid int) (int, error) {
+	stat, err := ioutil.ReadFile(filepath.Join(procDir, strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return 0, err
+	}
+	i := strings.LastIndexByte(string(stat), ')')
+	if i < 0 {
+		return 0, fmt.Errorf("Unable to parse stat of %d", pid)
+	}
+	fields := strings.Fields(string(stat[i+1:]))
+	if len(fields) < 2 {
+		return 0, fmt.Errorf("Unable to parse stat of %d", pid)
+	}
+	return strconv.Atoi(fields[1])
+}
+
+// inContainer reports if the cgroups, as listed in /proc/pid/cgroup, are
+// those of the container with the ID id. Every runtime names a cgroup of the
+// container after its ID, alone with cgroupfs or after the runtime with
+// systemd, and any cgroups below it are still the container's. The
+// crio-conmon-<id>.scope crio runs conmon in is not in the container.
+func inContainer(cgroups, id string) bool {
+	for _, line := range strings.Split(cgroups, "\n") {
+		fields := strings.SplitN(line, ":", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		for _, comp := range strings.Split(fields[2], "/") {
+			comp = strings.TrimSuffix(comp, ".scope")
+			if comp == id {
+				return true
+			}
+			for _, runtime := range containerRuntimes {
+				if comp == runtime+"-"+id {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// containerPid finds the first process of the container. Every process whose
+// cgroups are those of the container is in it, and the first is the one whose
+// parent is not.
+func containerPid(id string) (int, error) {
+	procs, err := ioutil.ReadDir(procDir)
+	if err != nil {
+		return 0, err
+	}
+	members := map[int]bool{}
+	for _, proc := range procs {
+		pid, err := strconv.Atoi(proc.Name())
+		if err != nil {
+			continue
+		}
+		cgroup, err := ioutil.ReadFile(filepath.Join(procDir, proc.Name(), "cgroup"))
+		if err != nil {
+			// the process exited
+			continue
+		}
+		if inContainer(string(cgroup), id) {
+			members[pid] = true
+		}
+	}
+	pids := make([]int, 0, len(members))
+	for pid := range members {
+		pids = append(pids, pid)
+	}
+	sort.Ints(pids)
+	for _, pid := range pids {
+		ppid, err := parentPid(pid)
+		if err == nil && !members[ppid] {
+			return pid, nil
+		}
+	}
+	if len(pids) > 0 {
+		return pids[0], nil
+	}
+	return 0, grpc.Errorf(codes.NotFound, "No processes found in container: %s", id)
+}
+
+// checkTarget checks the command may be run with, or without, the target
+func (exec *Exec) checkTarget(t *target) error {
+	if len(exec.targetNamespaces) > 0 && t == nil {
+		return fmt.Errorf("Command must be run in a target: %s", exec.CmdName)
+	}
+	if len(exec.targetNamespaces) == 0 && t != nil {
+		return fmt.Errorf("Command may not be run in a target: %s", exec.CmdName)
+	}
+	return nil
+}
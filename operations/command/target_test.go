@@ -0,0 +1,110 @@
+package command
+
+import (
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestContainerPid(t *testing.T) {
+	saved := procDir
+	procDir = "testdata/proc"
+	defer func() { procDir = saved }()
+
+	tests := []struct {
+		name string
+		id   string
+		pid  int
+	}{
+		{"docker with systemd, cgroup v1", strings.Repeat("a", 64), 100},
+		{"docker with cgroupfs, cgroup v1", strings.Repeat("b", 64), 200},
+		{"crio with systemd, cgroup v2, not conmon", strings.Repeat("c", 64), 300},
+		{"containerd with systemd, cgroup v2", strings.Repeat("d", 64), 400},
+		{"first process with the higher pid", strings.Repeat("f", 64), 600},
+		{"only part of an ID", strings.Repeat("a", 12), 0},
+		{"unknown", strings.Repeat("e", 64), 0},
+	}
+	for _, test := range tests {
+		pid, err := containerPid(test.id)
+		if test.pid == 0 {
+			if grpc.Code(err) != codes.NotFound {
+				t.Errorf("%s: expected NotFound, got %d, %v", test.name, pid, err)
+			}
+			continue
+		}
+		if err != nil || pid != test.pid {
+			t.Errorf("%s: expected %d, got %d, %v", test.name, test.pid, pid, err)
+		}
+	}
+}
+
+func TestParentPid(t *testing.T) {
+	saved := procDir
+	procDir = "testdata/proc"
+	defer func() { procDir = saved }()
+
+	tests := []struct {
+		pid  int
+		ppid int
+	}{
+		{100, 50},
+		{301, 300},
+		// the name is `a) S 1 (b`
+		{102, 100},
+	}
+	for _, test := range tests {
+		if ppid, err := parentPid(test.pid); err != nil || ppid != test.ppid {
+			t.Errorf("%d: expected parent %d, got %d, %v", test.pid, test.ppid, ppid, err)
+		}
+	}
+	if _, err := parentPid(12345); err == nil {
+		t.Errorf("expected an error for a process which does not exist")
+	}
+}
+
+func TestFindContainer(t *testing.T) {
+	pod := &corev1.Pod{}
+	pod.Namespace, pod.Name = "default", "web"
+	pod.Spec.Containers = []corev1.Container{{Name: "nginx"}, {Name: "sidecar"}}
+	pod.Status.ContainerStatuses = []corev1.ContainerStatus{
+		{Name: "sidecar", ContainerID: "cri-o://" + strings.Repeat("b", 64)},
+		{Name: "nginx", ContainerID: "docker://" + strings.Repeat("a", 64)},
+	}
+	tests := []struct {
+		name string
+		id   string
+		// found is the name of the container expected, empty if none is
+		found string
+	}{
+		{"", "", "nginx"},
+		{"nginx", "", "nginx"},
+		{"sidecar", "", "sidecar"},
+		{"", strings.Repeat("b", 64), "sidecar"},
+		{"", strings.Repeat("a", 12), "nginx"},
+		// the name wins over the ID
+		{"nginx", strings.Repeat("b", 64), "nginx"},
+		{"db", "", ""},
+		{"", strings.Repeat("c", 12), ""},
+		{"", "docker://" + strings.Repeat("a", 12), ""},
+	}
+	for _, test := range tests {
+		status, err := findContainer(pod, test.name, test.id)
+		if test.found == "" {
+			if grpc.Code(err) != codes.NotFound {
+				t.Errorf("%q %q: expected NotFound, got %v, %v", test.name, test.id, status, err)
+			}
+			continue
+		}
+		if err != nil || status.Name != test.found {
+			t.Errorf("%q %q: expected %s, got %v, %v", test.name, test.id, test.found, status, err)
+		}
+	}
+
+	empty := &corev1.Pod{}
+	if _, err := findContainer(empty, "", ""); grpc.Code(err) != codes.NotFound {
+		t.Errorf("expected NotFound for a pod without containers, got %v", err)
+	}
+}
@@ -0,0 +1,126 @@
+// Package nsenter runs a command in the namespaces of other processes. The
+// server re-executes itself with EnvName set, the constructor in nsexec.c
+// enters the namespaces before the Go runtime starts, and init here then
+// executes the command. Any binary which sets EnvName for itself must import
+// this package.
+package nsenter
+
+// #cgo CFLAGS: -Wall
+// int nsenter_cgroup_fd(int i);
+import "C"
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+const (
+	// EnvName lists what to enter, e.g. "mnt=1 net=42 root=1 cwd=1"
+	EnvName = "_ADMIN_RPC_NSENTER"
+	// CgroupEnvName lists, separated by `:`, the directories of the
+	// cgroups to join. They are opened before entering any namespace and
+	// joined just before the command is executed.
+	CgroupEnvName = "_ADMIN_RPC_CGROUPS"
+	// ErrorFD is where the helper writes why it could not run the command
+	ErrorFD = 3
+)
+
+// Error is why the command could not be run in its namespaces
+type Error struct {
+	// Op is what failed, e.g. "setns net" or "exec ls"
+	Op  string
+	Err syscall.Errno
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("Unable to %s: %v", e.Op, e.Err)
+}
+
+// ReadError reads from the other end of ErrorFD until the helper executes
+// the command or exits. It returns nil if the command was executed.
+func ReadError(r io.Reader) error {
+	buf, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	if len(buf) == 0 {
+		return nil
+	}
+	fields := bytes.SplitN(buf, []byte{0}, 2)
+	if len(fields) != 2 {
+		return fmt.Errorf("Unable to run command: %s", buf)
+	}
+	errno, err := strconv.Atoi(string(fields[1]))
+	if err != nil {
+		return fmt.Errorf("Unable to run command: %s", buf)
+	}
+	return &Error{
+		Op:  string(fields[0]),
+		Err: syscall.Errno(errno),
+	}
+}
+
+// fail tells the server what could not be done, the same way nsexec.c does
+func fail(op string, err error) {
+	errno, ok := err.(syscall.Errno)
+	if !ok {
+		errno = syscall.EINVAL
+	}
+	f := os.NewFile(ErrorFD, "nsenter-errors")
+	fmt.Fprintf(f, "%s\x00%d", op, int(errno))
+	os.Exit(127)
+}
+
+// joinCgroups moves the process into the cgroups nsexec opened. Only the
+// command, once executed, and what it starts should count against the limits
+// of its cgroups, so this is done last, while still privileged to do so. The
+// Go runtime then has a handful of threads, which the pids controller lets
+// migrate even above pids.max, and exec leaves a single one.
+func joinCgroups() {
+	for i := 0; ; i++ {
+		fd := int(C.nsenter_cgroup_fd(C.int(i)))
+		if fd < 0 {
+			return
+		}
+		// 0 is the process which writes it, with all of its threads
+		if _, err := syscall.Write(fd, []byte("0")); err != nil {
+			fail("join cgroup", err)
+		}
+		syscall.Close(fd)
+	}
+}
+
+// The namespaces were entered by nsexec, executes os.Args[1:] with the
+// privileges from the environment. The name is looked up in the PATH of the
+// new root.
+func init() {
+	if _, ok := os.LookupEnv(EnvName); !ok {
+		return
+	}
+	os.Unsetenv(EnvName)
+	os.Unsetenv(CgroupEnvName)
+	if len(os.Args) < 2 {
+		fail("exec", syscall.EINVAL)
+	}
+	name := os.Args[1]
+	path := name
+	if !strings.Contains(name, "/") {
+		var err error
+		if path, err = exec.LookPath(name); err != nil {
+			fail("exec "+name, syscall.ENOENT)
+		}
+	}
+	syscall.CloseOnExec(ErrorFD)
+	joinCgroups()
+	// Last, so the seccomp filter only has to permit the exec
+	applyPrivileges()
+	err := syscall.Exec(path, os.Args[1:], os.Environ())
+	fail("exec "+name, err)
+}
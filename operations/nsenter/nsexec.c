@@ -0,0 +1,259 @@
+// +build linux
+
+#define _GNU_SOURCE
+#include <errno.h>
+#include <fcntl.h>
+#include <limits.h>
+#include <sched.h>
+#include <signal.h>
+#include <stdio.h>
+#include <stdlib.h>
+#include <string.h>
+#include <sys/types.h>
+#include <sys/wait.h>
+#include <unistd.h>
+
+#ifndef CLONE_NEWCGROUP
+#define CLONE_NEWCGROUP 0x02000000
+#endif
+
+/* Keep in sync with EnvName, CgroupEnvName and ErrorFD in nsenter.go */
+#define NSENTER_ENV "_ADMIN_RPC_NSENTER"
+#define CGROUP_ENV "_ADMIN_RPC_CGROUPS"
+#define ERROR_FD 3
+
+struct ns_file {
+	const char *name;
+	int nstype;
+	int fd;
+};
+
+/*
+ * user must be first, it is skipped on the first pass. The mount namespace is
+ * last as it changes what /proc means.
+ */
+static struct ns_file namespaces[] = {
+	{ "user",   CLONE_NEWUSER,   -1 },
+	{ "cgroup", CLONE_NEWCGROUP, -1 },
+	{ "ipc",    CLONE_NEWIPC,    -1 },
+	{ "uts",    CLONE_NEWUTS,    -1 },
+	{ "net",    CLONE_NEWNET,    -1 },
+	{ "pid",    CLONE_NEWPID,    -1 },
+	{ "mnt",    CLONE_NEWNS,     -1 },
+	{ NULL,     0,               -1 },
+};
+
+/*
+ * fail tells the server what could not be done, as the operation and errno
+ * separated by a NUL, and exits. Nothing is written on success, the server
+ * sees EOF once the command is executed as ERROR_FD is closed on exec.
+ */
+static void fail(const char *op)
+{
+	char buf[512];
+	int saved = errno;
+	int len = snprintf(buf, sizeof(buf), "%s%c%d", op, '\0', saved);
+
+	if (len > (int)sizeof(buf))
+		len = sizeof(buf);
+	if (write(ERROR_FD, buf, len) < 0) {
+		/* nobody is left to tell */
+	}
+	_exit(127);
+}
+
+static int open_proc(const char *pid, const char *what)
+{
+	char path[128];
+	char op[160];
+	int fd;
+
+	snprintf(path, sizeof(path), "/proc/%s/%s", pid, what);
+	fd = open(path, O_RDONLY | O_CLOEXEC);
+	if (fd < 0) {
+		snprintf(op, sizeof(op), "open %s", path);
+		fail(op);
+	}
+	return fd;
+}
+
+/* The most cgroup hierarchies joined, one per controller is plenty */
+#define MAX_CGROUPS 16
+
+/*
+ * The cgroup.procs files of the cgroups to join, opened before entering any
+ * namespace changes where the cgroups are. nsenter.go joins them just before
+ * executing the command, so neither the threads of the Go runtime nor the
+ * parent left waiting after entering a pid namespace count against the limits
+ * of the command.
+ */
+static int cgroup_fds[MAX_CGROUPS];
+static int num_cgroup_fds;
+
+/* nsenter_cgroup_fd returns the i-th cgroup.procs opened, -1 after the last */
+int nsenter_cgroup_fd(int i)
+{
+	if (i < 0 || i >= num_cgroup_fds)
+		return -1;
+	return cgroup_fds[i];
+}
+
+/* open_cgroups opens the cgroup.procs of every cgroup listed in env */
+static void open_cgroups(const char *env)
+{
+	char *dirs, *dir, *save = NULL;
+
+	dirs = strdup(env);
+	if (dirs == NULL)
+		fail("parse cgroups");
+	for (dir = strtok_r(dirs, ":", &save); dir != NULL; dir = strtok_r(NULL, ":", &save)) {
+		char path[PATH_MAX];
+		char op[PATH_MAX + 16];
+		int fd;
+
+		if (num_cgroup_fds == MAX_CGROUPS) {
+			errno = E2BIG;
+			fail("parse cgroups");
+		}
+		snprintf(path, sizeof(path), "%s/cgroup.procs", dir);
+		snprintf(op, sizeof(op), "join cgroup %s", dir);
+		fd = open(path, O_WRONLY | O_CLOEXEC);
+		if (fd < 0)
+			fail(op);
+		cgroup_fds[num_cgroup_fds++] = fd;
+	}
+	free(dirs);
+}
+
+/*
+ * continue_as_child waits for the child, which runs the command, and exits
+ * the same way it did. Signals sent to the whole process group, as the server
+ * does to stop the command, are left to the child.
+ */
+static void continue_as_child(pid_t child)
+{
+	int status;
+
+	close(ERROR_FD);
+	signal(SIGINT, SIG_IGN);
+	signal(SIGQUIT, SIG_IGN);
+	signal(SIGTERM, SIG_IGN);
+	signal(SIGHUP, SIG_IGN);
+	while (waitpid(child, &status, 0) < 0) {
+		if (errno != EINTR)
+			_exit(127);
+	}
+	if (WIFSIGNALED(status)) {
+		signal(WTERMSIG(status), SIG_DFL);
+		kill(getpid(), WTERMSIG(status));
+		_exit(128 + WTERMSIG(status));
+	}
+	_exit(WEXITSTATUS(status));
+}
+
+/*
+ * nsexec enters the namespaces, root and working directory listed in the
+ * environment, e.g. "mnt=1 net=42 root=1 cwd=1", before the Go runtime starts
+ * any threads, as joining a user or mount namespace requires a single
+ * threaded process. It then returns so Go can execute the command.
+ */
+__attribute__((constructor)) static void nsexec(void)
+{
+	const char *env = getenv(NSENTER_ENV);
+	const char *cgroups = getenv(CGROUP_ENV);
+	char *spec, *field, *save = NULL;
+	int root_fd = -1, cwd_fd = -1, do_fork = 0;
+	int pass;
+	struct ns_file *ns;
+
+	if (env == NULL)
+		return;
+	/* Before entering a mount namespace changes where the cgroups are */
+	if (cgroups != NULL)
+		open_cgroups(cgroups);
+	spec = strdup(env);
+	if (spec == NULL)
+		fail("parse namespaces");
+
+	/* Open everything first, entering a namespace changes what /proc shows */
+	for (field = strtok_r(spec, " ", &save); field != NULL; field = strtok_r(NULL, " ", &save)) {
+		char *pid = strchr(field, '=');
+
+		if (pid == NULL) {
+			errno = EINVAL;
+			fail("parse namespaces");
+		}
+		*pid++ = '\0';
+		if (strcmp(field, "root") == 0) {
+			root_fd = open_proc(pid, "root");
+			continue;
+		}
+		if (strcmp(field, "cwd") == 0) {
+			cwd_fd = open_proc(pid, "cwd");
+			continue;
+		}
+		for (ns = namespaces; ns->name != NULL; ns++) {
+			if (strcmp(field, ns->name) == 0) {
+				char what[32];
+
+				snprintf(what, sizeof(what), "ns/%s", ns->name);
+				ns->fd = open_proc(pid, what);
+				break;
+			}
+		}
+		if (ns->name == NULL) {
+			errno = EINVAL;
+			fail("parse namespaces");
+		}
+	}
+	free(spec);
+
+	/*
+	 * Enter the user namespace last if that drops privileges, and first if
+	 * it gains them, the same way nsenter(1) does, by skipping it and
+	 * ignoring failures on the first pass.
+	 */
+	for (pass = 0; pass < 2; pass++) {
+		for (ns = namespaces + 1 - pass; ns->name != NULL; ns++) {
+			if (ns->fd < 0)
+				continue;
+			if (setns(ns->fd, ns->nstype) < 0) {
+				char op[32];
+
+				if (pass == 0)
+					continue;
+				snprintf(op, sizeof(op), "setns %s", ns->name);
+				fail(op);
+			}
+			if (ns->nstype == CLONE_NEWPID)
+				do_fork = 1;
+			close(ns->fd);
+			ns->fd = -1;
+		}
+	}
+
+	if (root_fd >= 0) {
+		if (fchdir(root_fd) < 0)
+			fail("chdir root");
+		if (chroot(".") < 0)
+			fail("chroot");
+		close(root_fd);
+	}
+	if (cwd_fd >= 0) {
+		if (fchdir(cwd_fd) < 0)
+			fail("chdir cwd");
+		close(cwd_fd);
+	} else if (root_fd >= 0 && chdir("/") < 0) {
+		fail("chdir /");
+	}
+
+	/* Only children are created in the pid namespace which was entered */
+	if (do_fork) {
+		pid_t child = fork();
+
+		if (child < 0)
+			fail("fork");
+		if (child > 0)
+			continue_as_child(child);
+	}
+}
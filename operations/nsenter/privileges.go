@@ -0,0 +1,178 @@
+package nsenter
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// PrivilegesEnvName holds the Privileges of the command, encoded as JSON
+const PrivilegesEnvName = "_ADMIN_RPC_PRIVILEGES"
+
+// capabilityNames are the capabilities of linux/capability.h in order, the
+// number of each is its index
+var capabilityNames = []string{
+	"CHOWN", "DAC_OVERRIDE", "DAC_READ_SEARCH", "FOWNER", "FSETID", "KILL",
+	"SETGID", "SETUID", "SETPCAP", "LINUX_IMMUTABLE", "NET_BIND_SERVICE",
+	"NET_BROADCAST", "NET_ADMIN", "NET_RAW", "IPC_LOCK", "IPC_OWNER",
+	"SYS_MODULE", "SYS_RAWIO", "SYS_CHROOT", "SYS_PTRACE", "SYS_PACCT",
+	"SYS_ADMIN", "SYS_BOOT", "SYS_NICE", "SYS_RESOURCE", "SYS_TIME",
+	"SYS_TTY_CONFIG", "MKNOD", "LEASE", "AUDIT_WRITE", "AUDIT_CONTROL",
+	"SETFCAP", "MAC_OVERRIDE", "MAC_ADMIN", "SYSLOG", "WAKE_ALARM",
+	"BLOCK_SUSPEND", "AUDIT_READ", "PERFMON", "BPF", "CHECKPOINT_RESTORE",
+}
+
+// ParseCapability returns the number of the capability called name. The
+// CAP_ prefix is optional and case does not matter, e.g. NET_RAW or
+// cap_net_raw.
+func ParseCapability(name string) (uint, error) {
+	upper := strings.TrimPrefix(strings.ToUpper(name), "CAP_")
+	for i, cap := range capabilityNames {
+		if cap == upper {
+			return uint(i), nil
+		}
+	}
+	return 0, fmt.Errorf("Unknown capability: %s", name)
+}
+
+// Privileges are what the command may do, set once the namespaces were
+// entered and just before the command is executed
+type Privileges struct {
+	// RunAs, if set, is the uid, gid and supplementary groups of the
+	// command instead of those of the server
+	RunAs *IDs `json:"runAs,omitempty"`
+	// Capabilities, if not nil, are the only capabilities the command
+	// keeps, also when it runs as another user
+	Capabilities []uint `json:"capabilities"`
+	// NoNewPrivs stops the command gaining privileges by executing setuid
+	// binaries or binaries with file capabilities
+	NoNewPrivs bool `json:"noNewPrivs,omitempty"`
+	// Seccomp, if set, is a compiled seccomp filter, an array of struct
+	// sock_filter. It is loaded last, so it must permit execve.
+	Seccomp []byte `json:"seccomp,omitempty"`
+}
+
+// IDs are who a command runs as
+type IDs struct {
+	UID    int   `json:"uid"`
+	GID    int   `json:"gid"`
+	Groups []int `json:"groups,omitempty"`
+}
+
+// Encode returns the privileges as the value of PrivilegesEnvName
+func (p *Privileges) Encode() (string, error) {
+	buf, err := json.Marshal(p)
+	if err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
+
+// capabilitySets returns the capabilities as the two halves of a
+// _LINUX_CAPABILITY_VERSION_3 set
+func capabilitySets(caps []uint) [2]uint32 {
+	var sets [2]uint32
+	for _, cap := range caps {
+		sets[cap/32] |= 1 << (cap % 32)
+	}
+	return sets
+}
+
+// apply sets the privileges of the calling thread. The command is executed
+// from the same thread, the Go runtime may run other threads which keep the
+// privileges of the server until the exec ends them.
+func (p *Privileges) apply() (string, error) {
+	if p.Capabilities != nil {
+		keep := map[uint]bool{}
+		for _, cap := range p.Capabilities {
+			keep[cap] = true
+		}
+		// The bounding set limits what executing any binary can grant,
+		// including one of root. EINVAL means there are no more.
+		for cap := uint(0); ; cap++ {
+			if keep[cap] {
+				continue
+			}
+			if err := unix.Prctl(unix.PR_CAPBSET_DROP, uintptr(cap), 0, 0, 0); err == unix.EINVAL {
+				break
+			} else if err != nil {
+				return "drop capabilities", err
+			}
+		}
+		if err := unix.Prctl(unix.PR_SET_KEEPCAPS, 1, 0, 0, 0); err != nil {
+			return "keep capabilities", err
+		}
+	}
+	if p.RunAs != nil {
+		if err := unix.Setgroups(p.RunAs.Groups); err != nil {
+			return "setgroups", err
+		}
+		if err := unix.Setgid(p.RunAs.GID); err != nil {
+			return "setgid", err
+		}
+		if err := unix.Setuid(p.RunAs.UID); err != nil {
+			return "setuid", err
+		}
+	}
+	if p.Capabilities != nil {
+		sets := capabilitySets(p.Capabilities)
+		hdr := unix.CapUserHeader{Version: unix.LINUX_CAPABILITY_VERSION_3}
+		var data [2]unix.CapUserData
+		for i := range data {
+			data[i].Effective = sets[i]
+			data[i].Permitted = sets[i]
+			data[i].Inheritable = sets[i]
+		}
+		if err := unix.Capset(&hdr, &data[0]); err != nil {
+			return "set capabilities", err
+		}
+		// Ambient capabilities survive executing a binary as a user
+		// other than root
+		for _, cap := range p.Capabilities {
+			if err := unix.Prctl(unix.PR_CAP_AMBIENT, unix.PR_CAP_AMBIENT_RAISE, uintptr(cap), 0, 0); err != nil {
+				return "raise ambient capabilities", err
+			}
+		}
+	}
+	// The seccomp filter may only be loaded without CAP_SYS_ADMIN with
+	// no_new_privs set
+	if p.NoNewPrivs || len(p.Seccomp) > 0 {
+		if err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+			return "set no_new_privs", err
+		}
+	}
+	if len(p.Seccomp) > 0 {
+		prog := unix.SockFprog{
+			Len:    uint16(len(p.Seccomp) / int(unsafe.Sizeof(unix.SockFilter{}))),
+			Filter: (*unix.SockFilter)(unsafe.Pointer(&p.Seccomp[0])),
+		}
+		if err := unix.Prctl(unix.PR_SET_SECCOMP, unix.SECCOMP_MODE_FILTER, uintptr(unsafe.Pointer(&prog)), 0, 0); err != nil {
+			return "load seccomp filter", err
+		}
+	}
+	return "", nil
+}
+
+// applyPrivileges sets the privileges from the environment, if any, and
+// keeps the goroutine on the thread it set them on
+func applyPrivileges() {
+	env, ok := os.LookupEnv(PrivilegesEnvName)
+	if !ok {
+		return
+	}
+	os.Unsetenv(PrivilegesEnvName)
+	runtime.LockOSThread()
+	p := &Privileges{}
+	if err := json.Unmarshal([]byte(env), p); err != nil {
+		fail("parse privileges", syscall.EINVAL)
+	}
+	if op, err := p.apply(); err != nil {
+		fail(op, err)
+	}
+}
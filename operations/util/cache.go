@@ -0,0 +1,108 @@
+package util
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var cacheLookups = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "admin_rpc_auth_cache_lookups_total",
+	Help: "Number of lookups in the caches of TokenReviews and SubjectAccessReviews, by cache and result, hit or miss.",
+}, []string{"cache", "result"})
+
+func init() {
+	prometheus.MustRegister(cacheLookups)
+}
+
+// Cache holds up to size results, dropping the least recently used when it
+// is full. Results expire after ttl, or negativeTTL for negative results. A
+// ttl of 0 means those results are not cached. It is safe for concurrent use.
+type Cache struct {
+	name        string
+	size        int
+	ttl         time.Duration
+	negativeTTL time.Duration
+	// now is the clock entries expire by
+	now func() time.Time
+
+	lock    sync.Mutex
+	lru     *list.List
+	entries map[string]*list.Element
+}
+
+type cacheEntry struct {
+	key     string
+	value   interface{}
+	expires time.Time
+}
+
+// NewCache returns an empty cache. name is the cache label of its metrics.
+func NewCache(name string, size int, ttl, negativeTTL time.Duration) *Cache {
+	return &Cache{
+		name:        name,
+		size:        size,
+		ttl:         ttl,
+		negativeTTL: negativeTTL,
+		now:         time.Now,
+		lru:         list.New(),
+		entries:     map[string]*list.Element{},
+	}
+}
+
+// Get returns the result cached for key, if it has not expired
+func (c *Cache) Get(key string) (interface{}, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	elem, ok := c.entries[key]
+	if ok && c.now().After(elem.Value.(*cacheEntry).expires) {
+		c.lru.Remove(elem)
+		delete(c.entries, key)
+		ok = false
+	}
+	if !ok {
+		cacheLookups.WithLabelValues(c.name, "miss").Inc()
+		return nil, false
+	}
+	cacheLookups.WithLabelValues(c.name, "hit").Inc()
+	c.lru.MoveToFront(elem)
+	return elem.Value.(*cacheEntry).value, true
+}
+
+// Add caches the result for key. positive picks which ttl applies.
+func (c *Cache) Add(key string, value interface{}, positive bool) {
+	if c == nil || c.size <= 0 {
+		return
+	}
+	ttl := c.ttl
+	if !positive {
+		ttl = c.negativeTTL
+	}
+	if ttl <= 0 {
+		return
+	}
+	entry := &cacheEntry{
+		key:     key,
+		value:   value,
+		expires: c.now().Add(ttl),
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if elem, ok := c.entries[key]; ok {
+		elem.Value = entry
+		c.lru.MoveToFront(elem)
+		return
+	}
+	c.entries[key] = c.lru.PushFront(entry)
+	for c.lru.Len() > c.size {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
@@ -0,0 +1,128 @@
+package util
+
+import (
+	"testing"
+	"time"
+)
+
+// testClock is a clock which only moves when told to
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	return c.now
+}
+
+func newTestCache(size int, ttl, negativeTTL time.Duration) (*Cache, *testClock) {
+	clock := &testClock{now: time.Unix(1000, 0)}
+	c := NewCache("test", size, ttl, negativeTTL)
+	c.now = clock.Now
+	return c, clock
+}
+
+func TestCache(t *testing.T) {
+	type step struct {
+		// after is how long after the previous step this one is
+		after time.Duration
+		// add, if set, is added with positive, otherwise get is looked up
+		add      string
+		positive bool
+		get      string
+		// found is if get is expected to be cached
+		found bool
+	}
+	tests := []struct {
+		name        string
+		size        int
+		ttl         time.Duration
+		negativeTTL time.Duration
+		steps       []step
+	}{
+		{"positive ttl", 10, time.Minute, time.Second, []step{
+			{add: "a", positive: true},
+			{after: 59 * time.Second, get: "a", found: true},
+			{after: 2 * time.Second, get: "a", found: false},
+			// once expired it stays gone
+			{after: -time.Minute, get: "a", found: false},
+		}},
+		{"negative ttl", 10, time.Minute, 10 * time.Second, []step{
+			{add: "denied", positive: false},
+			{add: "allowed", positive: true},
+			{after: 9 * time.Second, get: "denied", found: true},
+			{after: 2 * time.Second, get: "denied", found: false},
+			{get: "allowed", found: true},
+		}},
+		{"negative not cached", 10, time.Minute, 0, []step{
+			{add: "denied", positive: false},
+			{get: "denied", found: false},
+		}},
+		{"positive not cached", 10, 0, time.Minute, []step{
+			{add: "allowed", positive: true},
+			{get: "allowed", found: false},
+		}},
+		{"size 0", 0, time.Minute, time.Minute, []step{
+			{add: "a", positive: true},
+			{get: "a", found: false},
+		}},
+		{"readding resets the ttl", 10, time.Minute, time.Second, []step{
+			{add: "a", positive: true},
+			{after: 50 * time.Second, add: "a", positive: true},
+			{after: 50 * time.Second, get: "a", found: true},
+			{after: 11 * time.Second, get: "a", found: false},
+		}},
+		{"least recently used evicted", 2, time.Minute, time.Minute, []step{
+			{add: "a", positive: true},
+			{add: "b", positive: true},
+			{add: "c", positive: true},
+			{get: "a", found: false},
+			{get: "b", found: true},
+			{get: "c", found: true},
+		}},
+		{"get keeps an entry", 2, time.Minute, time.Minute, []step{
+			{add: "a", positive: true},
+			{add: "b", positive: true},
+			{get: "a", found: true},
+			{add: "c", positive: true},
+			{get: "b", found: false},
+			{get: "a", found: true},
+			{get: "c", found: true},
+		}},
+		{"add keeps an entry", 2, time.Minute, time.Minute, []step{
+			{add: "a", positive: true},
+			{add: "b", positive: true},
+			{add: "a", positive: true},
+			{add: "c", positive: true},
+			{get: "b", found: false},
+			{get: "a", found: true},
+		}},
+	}
+	for _, test := range tests {
+		c, clock := newTestCache(test.size, test.ttl, test.negativeTTL)
+		for i, step := range test.steps {
+			clock.now = clock.now.Add(step.after)
+			if step.add != "" {
+				c.Add(step.add, step.add, step.positive)
+				continue
+			}
+			val, found := c.Get(step.get)
+			if found != step.found {
+				t.Errorf("%s: step %d: expected found %t for %s, got %t", test.name, i, step.found, step.get, found)
+			}
+			if found && val != step.get {
+				t.Errorf("%s: step %d: expected %s, got %v", test.name, i, step.get, val)
+			}
+		}
+		if len(c.entries) != c.lru.Len() || len(c.entries) > test.size {
+			t.Errorf("%s: %d entries and %d in the lru list, size %d", test.name, len(c.entries), c.lru.Len(), test.size)
+		}
+	}
+}
+
+func TestNilCache(t *testing.T) {
+	var c *Cache
+	c.Add("a", true, true)
+	if _, found := c.Get("a"); found {
+		t.Errorf("nil cache returned an entry")
+	}
+}
@@ -0,0 +1,325 @@
+package util
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+const (
+	// cgroupRoot is where the server sees the cgroup filesystems of the
+	// host. Its own may be a namespaced view of only the cgroup of the pod.
+	cgroupRoot = "/proc/1/root/sys/fs/cgroup"
+	// cgroupParent holds the cgroup of every command, below the root of
+	// every hierarchy
+	cgroupParent = "admin-rpc"
+	// cpuPeriodUsec is the period CPU quotas are enforced over
+	cpuPeriodUsec = 100000
+	// cgroupPollInterval is how often the cgroup is checked for breached
+	// limits
+	cgroupPollInterval = 100 * time.Millisecond
+)
+
+// MinCPUMillis is the smallest CPUMillis, the kernel refuses a quota below
+// 1ms for each period
+const MinCPUMillis = 1000 * 1000 / cpuPeriodUsec
+
+// The range of IOWeight, the same as io.weight of cgroup v2
+const (
+	MinIOWeight = 1
+	MaxIOWeight = 10000
+)
+
+// Limits are the resources a command, and everything it starts, may use. 0
+// means no limit.
+type Limits struct {
+	// CPUMillis is the CPU time per second, in thousandths of a CPU, at
+	// least MinCPUMillis
+	CPUMillis int64
+	// MemoryBytes is the most memory, the command is killed if it needs more
+	MemoryBytes int64
+	// Pids is the most processes and threads, the command is killed if it
+	// tries to start more. Only the command and what it starts count, the
+	// nsenter helper joins the cgroup just before executing the command.
+	Pids int64
+	// IOWeight is the share of disk IO, from MinIOWeight to MaxIOWeight
+	IOWeight uint64
+}
+
+// cgroupSeq numbers the cgroups of this server, so their names are unique
+var cgroupSeq uint64
+
+// cgroup is the transient cgroup a command runs in. It is created before the
+// command is started and removed once it exited.
+type cgroup struct {
+	limits *Limits
+	v2     bool
+	// dirs are the directories of the cgroup, one for each hierarchy with
+	// cgroup v1, with the one tracking the processes first
+	dirs []string
+}
+
+func writeCgroupFile(dir, file, value string) error {
+	return ioutil.WriteFile(filepath.Join(dir, file), []byte(value), 0644)
+}
+
+// newCgroup creates a cgroup with the limits
+func newCgroup(limits *Limits) (*cgroup, error) {
+	name := fmt.Sprintf("%d-%d", os.Getpid(), atomic.AddUint64(&cgroupSeq, 1))
+	cg := &cgroup{limits: limits}
+	_, err := os.Stat(filepath.Join(cgroupRoot, "cgroup.controllers"))
+	cg.v2 = err == nil
+	if cg.v2 {
+		err = cg.setupV2(name)
+	} else {
+		err = cg.setupV1(name)
+	}
+	if err != nil {
+		cg.remove()
+		return nil, err
+	}
+	return cg, nil
+}
+
+// mkdir creates the cgroup called name below dir
+func (cg *cgroup) mkdir(dir, name string) (string, error) {
+	parent := filepath.Join(dir, cgroupParent)
+	if err := os.MkdirAll(parent, 0755); err != nil {
+		return "", err
+	}
+	path := filepath.Join(parent, name)
+	if err := os.Mkdir(path, 0755); err != nil {
+		return "", err
+	}
+	cg.dirs = append(cg.dirs, path)
+	return path, nil
+}
+
+// setupV2 creates the cgroup in the unified hierarchy, after making the
+// controllers the limits need available to it
+func (cg *cgroup) setupV2(name string) error {
+	l := cg.limits
+	controllers := []string{}
+	if l.CPUMillis > 0 {
+		controllers = append(controllers, "cpu")
+	}
+	if l.MemoryBytes > 0 {
+		controllers = append(controllers, "memory")
+	}
+	if l.Pids > 0 {
+		controllers = append(controllers, "pids")
+	}
+	if l.IOWeight > 0 {
+		controllers = append(controllers, "io")
+	}
+	if err := os.MkdirAll(filepath.Join(cgroupRoot, cgroupParent), 0755); err != nil {
+		return err
+	}
+	for _, dir := range []string{cgroupRoot, filepath.Join(cgroupRoot, cgroupParent)} {
+		for _, c := range controllers {
+			if err := writeCgroupFile(dir, "cgroup.subtree_control", "+"+c); err != nil {
+				return fmt.Errorf("Unable to enable the %s controller: %v", c, err)
+			}
+		}
+	}
+	dir, err := cg.mkdir(cgroupRoot, name)
+	if err != nil {
+		return err
+	}
+	if l.CPUMillis > 0 {
+		quota := l.CPUMillis * cpuPeriodUsec / 1000
+		if err := writeCgroupFile(dir, "cpu.max", fmt.Sprintf("%d %d", quota, cpuPeriodUsec)); err != nil {
+			return err
+		}
+	}
+	if l.MemoryBytes > 0 {
+		if err := writeCgroupFile(dir, "memory.max", strconv.FormatInt(l.MemoryBytes, 10)); err != nil {
+			return err
+		}
+		// Swapping would hide the breach. Kernels without swap accounting
+		// lack the file, as do older kernels the group setting.
+		writeCgroupFile(dir, "memory.swap.max", "0")
+		writeCgroupFile(dir, "memory.oom.group", "1")
+	}
+	if l.Pids > 0 {
+		if err := writeCgroupFile(dir, "pids.max", strconv.FormatInt(l.Pids, 10)); err != nil {
+			return err
+		}
+	}
+	if l.IOWeight > 0 {
+		// io.weight needs the io.cost controller, io.bfq.weight the BFQ
+		// scheduler
+		err := writeCgroupFile(dir, "io.weight", fmt.Sprintf("default %d", l.IOWeight))
+		if err != nil {
+			err = writeCgroupFile(dir, "io.bfq.weight", strconv.FormatUint(l.IOWeight, 10))
+		}
+		if err != nil {
+			return fmt.Errorf("Unable to set the io weight: %v", err)
+		}
+	}
+	return nil
+}
+
+// blkioWeight converts an io.weight into the range of blkio.weight, 10 to
+// 1000, the same way systemd does
+func blkioWeight(weight uint64) uint64 {
+	return 10 + (weight-MinIOWeight)*(1000-10)/(MaxIOWeight-MinIOWeight)
+}
+
+// setupV1 creates the cgroup in the hierarchy of every controller the limits
+// need. The pids hierarchy is always used, it tracks the processes.
+func (cg *cgroup) setupV1(name string) error {
+	l := cg.limits
+	dir, err := cg.mkdir(filepath.Join(cgroupRoot, "pids"), name)
+	if err != nil {
+		return err
+	}
+	if l.Pids > 0 {
+		if err := writeCgroupFile(dir, "pids.max", strconv.FormatInt(l.Pids, 10)); err != nil {
+			return err
+		}
+	}
+	if l.CPUMillis > 0 {
+		dir, err := cg.mkdir(filepath.Join(cgroupRoot, "cpu"), name)
+		if err != nil {
+			return err
+		}
+		quota := l.CPUMillis * cpuPeriodUsec / 1000
+		if err := writeCgroupFile(dir, "cpu.cfs_period_us", strconv.Itoa(cpuPeriodUsec)); err != nil {
+			return err
+		}
+		if err := writeCgroupFile(dir, "cpu.cfs_quota_us", strconv.FormatInt(quota, 10)); err != nil {
+			return err
+		}
+	}
+	if l.MemoryBytes > 0 {
+		dir, err := cg.mkdir(filepath.Join(cgroupRoot, "memory"), name)
+		if err != nil {
+			return err
+		}
+		limit := strconv.FormatInt(l.MemoryBytes, 10)
+		if err := writeCgroupFile(dir, "memory.limit_in_bytes", limit); err != nil {
+			return err
+		}
+		// Swapping would hide the breach. Kernels without swap accounting
+		// lack the file.
+		writeCgroupFile(dir, "memory.memsw.limit_in_bytes", limit)
+	}
+	if l.IOWeight > 0 {
+		dir, err := cg.mkdir(filepath.Join(cgroupRoot, "blkio"), name)
+		if err != nil {
+			return err
+		}
+		weight := strconv.FormatUint(blkioWeight(l.IOWeight), 10)
+		// Kernels with the BFQ scheduler instead of CFQ only have the
+		// second
+		err = writeCgroupFile(dir, "blkio.weight", weight)
+		if err != nil {
+			err = writeCgroupFile(dir, "blkio.bfq.weight", weight)
+		}
+		if err != nil {
+			return fmt.Errorf("Unable to set the io weight: %v", err)
+		}
+	}
+	return nil
+}
+
+// eventCount returns the counter called key of a flat keyed file, such as
+// memory.events, or 0 if it can not be read
+func eventCount(path, key string) int64 {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[0] == key {
+			n, _ := strconv.ParseInt(fields[1], 10, 64)
+			return n
+		}
+	}
+	return 0
+}
+
+// breached returns the reason for the limit the command breached, or "" if
+// it breached none
+func (cg *cgroup) breached() string {
+	for _, dir := range cg.dirs {
+		memory := filepath.Join(dir, "memory.oom_control")
+		if cg.v2 {
+			memory = filepath.Join(dir, "memory.events")
+		}
+		if cg.limits.MemoryBytes > 0 && eventCount(memory, "oom_kill") > 0 {
+			return ReasonMemoryLimit
+		}
+		if cg.limits.Pids > 0 && eventCount(filepath.Join(dir, "pids.events"), "max") > 0 {
+			return ReasonPidsLimit
+		}
+	}
+	return ""
+}
+
+// watch checks the limits until done is closed and sends the reason for the
+// first breached one
+func (cg *cgroup) watch(done <-chan struct{}, breach chan<- string) {
+	ticker := time.NewTicker(cgroupPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if reason := cg.breached(); reason != "" {
+				breach <- reason
+				return
+			}
+		}
+	}
+}
+
+// kill sends SIGKILL to everything in the cgroup. Processes may fork while
+// they are being killed so it goes over them until none are left.
+func (cg *cgroup) kill() {
+	if len(cg.dirs) == 0 {
+		return
+	}
+	dir := cg.dirs[0]
+	if cg.v2 && writeCgroupFile(dir, "cgroup.kill", "1") == nil {
+		return
+	}
+	for i := 0; i < 10; i++ {
+		procs, err := ioutil.ReadFile(filepath.Join(dir, "cgroup.procs"))
+		if err != nil || len(procs) == 0 {
+			return
+		}
+		for _, field := range strings.Fields(string(procs)) {
+			if pid, err := strconv.Atoi(field); err == nil {
+				syscall.Kill(pid, syscall.SIGKILL)
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// remove kills anything left in the cgroup and removes it. A cgroup can
+// only be removed once the processes killed in it are gone.
+func (cg *cgroup) remove() {
+	cg.kill()
+	for _, dir := range cg.dirs {
+		for i := 0; i < 100; i++ {
+			if err := os.Remove(dir); err == nil || os.IsNotExist(err) {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+}
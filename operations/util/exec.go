@@ -5,22 +5,123 @@ import (
 	"io"
 	"os"
 	"os/exec"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/kr/pty"
+	"golang.org/x/net/context"
+	"golang.org/x/sys/unix"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 
 	rpcapi "github.com/eparis/admin-rpc/api"
+	"github.com/eparis/admin-rpc/operations/nsenter"
 )
 
-type streamWriter struct {
-	stream rpcapi.Exec_SendExecServer
+// ReplyStream is the part of the SendExec and StreamExec server streams used
+// to send output back to the client.
+type ReplyStream interface {
+	Send(*rpcapi.ExecReply) error
+	Context() context.Context
+}
+
+// StdinReader reads the stdin sent by the client in the messages of a
+// StreamExec which follow the request. Terminal size changes are passed on
+// to resize.
+type StdinReader struct {
+	stream rpcapi.Exec_StreamExecServer
+	resize chan<- *rpcapi.WindowSize
+	// data is the stdin of every message. err, set before data is closed,
+	// is what Read returns once it is drained.
+	data chan []byte
+	err  error
+	buf  []byte
+}
+
+// NewStdinReader takes the first message of the stream, as stdin may be sent
+// along with the request. resize may be nil if the session has no terminal.
+func NewStdinReader(stream rpcapi.Exec_StreamExecServer, first *rpcapi.ExecInput, resize chan<- *rpcapi.WindowSize) *StdinReader {
+	sr := &StdinReader{
+		stream: stream,
+		resize: resize,
+		data:   make(chan []byte),
+		buf:    first.Stdin,
+	}
+	go sr.receive(first.Eof)
+	return sr
 }
 
-func (sw streamWriter) Write(p []byte) (int, error) {
-	cr := &rpcapi.ExecReply{
-		Output: p,
+// receive reads every message of the stream, handing stdin to Read and
+// terminal size changes to resize. It keeps receiving after the client sent
+// Eof so the terminal can still be resized.
+func (sr *StdinReader) receive(eof bool) {
+	ctx := sr.stream.Context()
+	closed := false
+	finish := func(err error) {
+		if !closed {
+			sr.err = err
+			close(sr.data)
+			closed = true
+		}
+	}
+	defer finish(io.ErrUnexpectedEOF)
+	if eof {
+		finish(io.EOF)
+	}
+	for {
+		in, err := sr.stream.Recv()
+		if err != nil {
+			// io.EOF here means the client closed its side of the stream
+			finish(err)
+			return
+		}
+		if in.Request != nil {
+			finish(fmt.Errorf("Request may only be sent in the first message"))
+			return
+		}
+		if in.Resize != nil {
+			if sr.resize == nil {
+				finish(fmt.Errorf("Resize is only valid for tty sessions"))
+				return
+			}
+			select {
+			case sr.resize <- in.Resize:
+			case <-ctx.Done():
+				finish(ctx.Err())
+				return
+			}
+		}
+		// stdin sent after Eof is dropped
+		if closed {
+			continue
+		}
+		if len(in.Stdin) > 0 {
+			select {
+			case sr.data <- in.Stdin:
+			case <-ctx.Done():
+				finish(ctx.Err())
+				return
+			}
+		}
+		if in.Eof {
+			finish(io.EOF)
+		}
 	}
-	if err := sw.stream.Send(cr); err != nil {
-		return 0, err
+}
+
+func (sr *StdinReader) Read(p []byte) (int, error) {
+	for len(sr.buf) == 0 {
+		buf, ok := <-sr.data
+		if !ok {
+			return 0, sr.err
+		}
+		sr.buf = buf
 	}
-	return len(p), nil
+	n := copy(p, sr.buf)
+	sr.buf = sr.buf[n:]
+	return n, nil
 }
 
 var (
@@ -38,142 +139,434 @@ var (
 	}
 )
 
+// Namespaces are the pids whose namespaces the command enters. 0 means the
+// namespace of the server itself.
 type Namespaces struct {
-	Mount int // /proc/pid/ns/mnt
-	Uts   int // /proc/pid/ns/uts
-	IPC   int // /proc/pid/ns/ipc
-	Net   int // /proc/pid/ns/net
-	Pid   int // /proc/pid/ns/pid
-	// The RHEL7 version of nsenter does not support User or Cgroup
-	// User   int // /proc/pid/ns/user
-	// Cgroup int // /proc/pid/ns/cgroup
-	Root int // /proc/pid/root
-	Cwd  int // /proc/pid/cwd
-}
-
-func (n Namespaces) args() []string {
-	args := []string{}
-	if n.Mount != 0 {
-		args = append(args, fmt.Sprintf("--mount=/proc/%d/ns/mnt", n.Mount))
-	} else {
-		args = append(args, "--mount=/proc/self/ns/mnt")
+	Mount  int // /proc/pid/ns/mnt
+	Uts    int // /proc/pid/ns/uts
+	IPC    int // /proc/pid/ns/ipc
+	Net    int // /proc/pid/ns/net
+	Pid    int // /proc/pid/ns/pid
+	User   int // /proc/pid/ns/user
+	Cgroup int // /proc/pid/ns/cgroup
+	Root   int // /proc/pid/root
+	Cwd    int // /proc/pid/cwd
+}
+
+// env describes the namespaces for the nsenter helper, e.g.
+// "mnt=1 net=42 root=1 cwd=1". Namespaces which are 0 are not entered.
+func (n Namespaces) env() string {
+	fields := []string{}
+	for _, ns := range []struct {
+		name string
+		pid  int
+	}{
+		{"user", n.User},
+		{"cgroup", n.Cgroup},
+		{"ipc", n.IPC},
+		{"uts", n.Uts},
+		{"net", n.Net},
+		{"pid", n.Pid},
+		{"mnt", n.Mount},
+		{"root", n.Root},
+		{"cwd", n.Cwd},
+	} {
+		if ns.pid != 0 {
+			fields = append(fields, fmt.Sprintf("%s=%d", ns.name, ns.pid))
+		}
 	}
-	if n.Uts != 0 {
-		args = append(args, fmt.Sprintf("--uts=/proc/%d/ns/uts", n.Uts))
-	} else {
-		args = append(args, "--uts=/proc/self/ns/uts")
+	return strings.Join(fields, " ")
+}
+
+// execStatus builds the final status message for a process which has been
+// waited on.
+func execStatus(state *os.ProcessState, wallTime time.Duration) *rpcapi.ExecStatus {
+	status := &rpcapi.ExecStatus{
+		WallTimeUsec:   int64(wallTime / time.Microsecond),
+		UserTimeUsec:   int64(state.UserTime() / time.Microsecond),
+		SystemTimeUsec: int64(state.SystemTime() / time.Microsecond),
 	}
-	if n.IPC != 0 {
-		args = append(args, fmt.Sprintf("--ipc=/proc/%d/ns/ipc", n.IPC))
-	} else {
-		args = append(args, "--ipc=/proc/self/ns/ipc")
+	if ws, ok := state.Sys().(syscall.WaitStatus); ok {
+		switch {
+		case ws.Exited():
+			status.ExitCode = int32(ws.ExitStatus())
+		case ws.Signaled():
+			status.ExitCode = int32(128 + ws.Signal())
+			status.Signal = unix.SignalName(ws.Signal())
+		}
 	}
-	if n.Net != 0 {
-		args = append(args, fmt.Sprintf("--net=/proc/%d/ns/net", n.Net))
-	} else {
-		args = append(args, "--net=/proc/self/ns/net")
+	if ru, ok := state.SysUsage().(*syscall.Rusage); ok {
+		status.MaxRssKb = ru.Maxrss
+		status.MinorFaults = ru.Minflt
+		status.MajorFaults = ru.Majflt
+		status.InBlocks = ru.Inblock
+		status.OutBlocks = ru.Oublock
+		status.VoluntaryCtxSwitches = ru.Nvcsw
+		status.InvoluntaryCtxSwitches = ru.Nivcsw
 	}
-	if n.Pid != 0 {
-		args = append(args, fmt.Sprintf("--pid=/proc/%d/ns/pid", n.Pid))
-	} else {
-		args = append(args, "--pid=/proc/self/ns/pid")
-	}
-	/*
-		if n.User != 0 {
-			args = append(args, fmt.Sprintf("--user=/proc/%d/ns/user", n.User))
-		} else {
-			args = append(args, "--user=/proc/self/ns/user")
-		}
-		if n.Cgroup != 0 {
-			args = append(args, fmt.Sprintf("--cgroup=/proc/%d/ns/cgroup", n.Cgroup))
-		} else {
-			args = append(args, "--cgroup=/proc/self/ns/cgroup")
-		}
-	*/
-	if n.Root != 0 {
-		args = append(args, fmt.Sprintf("--root=/proc/%d/root", n.Root))
-	} else {
-		args = append(args, "--root=/proc/self/root")
+	return status
+}
+
+// ExecOptions control how a command is executed
+type ExecOptions struct {
+	// Stdin is fed to the process. If nil the process reads from /dev/null.
+	Stdin io.Reader
+	// TTY runs the process on a pseudo terminal of the given initial size.
+	// All output is then sent to the client as STDOUT.
+	TTY *rpcapi.WindowSize
+	// Resize delivers changes to the size of the terminal
+	Resize <-chan *rpcapi.WindowSize
+	// Timeout terminates the process if it runs longer. 0 means no timeout.
+	Timeout time.Duration
+	// GracePeriod is how long to wait after SIGTERM before sending SIGKILL
+	// when terminating the process. 0 means DefaultGracePeriod.
+	GracePeriod time.Duration
+	// Limits, if set, are enforced by running the process in a cgroup of
+	// its own
+	Limits *Limits
+	// Privileges, if set, replace those of the server
+	Privileges *nsenter.Privileges
+	// MaxOutputBytes is the most output, stdout and stderr together, sent
+	// to the client. The process is terminated once it writes more. 0
+	// means no limit.
+	MaxOutputBytes int64
+	// OutputBytesPerSecond limits how fast output is sent to the client,
+	// the process is slowed down by its full pipes. 0 means no limit.
+	OutputBytesPerSecond int64
+}
+
+// Why the server killed a command, reported as the reason of its status
+const (
+	ReasonMemoryLimit = "MemoryLimit"
+	ReasonPidsLimit   = "PidsLimit"
+	ReasonOutputLimit = "OutputLimit"
+)
+
+// DefaultGracePeriod is used when ExecOptions.GracePeriod is not set
+const DefaultGracePeriod = 5 * time.Second
+
+// process is a started command along with the goroutines moving its input
+// and output. The command is always the leader of its own process group so
+// the whole group can be terminated.
+type process struct {
+	cmd    *exec.Cmd
+	opts   ExecOptions
+	start  time.Time
+	waited chan error    // receives the result of cmd.Wait()
+	exited chan struct{} // closed once cmd.Wait() returned
+	copied chan struct{} // closed once all output has been sent
+	cgroup *cgroup       // nil unless the process has limits
+	output *output
+}
+
+func newProcess(cmd *exec.Cmd, opts ExecOptions, stream ReplyStream) *process {
+	return &process{
+		cmd:    cmd,
+		opts:   opts,
+		output: newOutput(stream, opts),
+		start:  time.Now(),
+		waited: make(chan error, 1),
+		exited: make(chan struct{}),
+		copied: make(chan struct{}),
 	}
-	if n.Cwd != 0 {
-		args = append(args, fmt.Sprintf("--wd=/proc/%d/cwd", n.Cwd))
-	} else {
-		args = append(args, "--wd=/proc/self/cwd")
+}
+
+// reap waits for the process to exit and then runs cleanup
+func (p *process) reap(cleanup func()) {
+	err := p.cmd.Wait()
+	close(p.exited)
+	p.waited <- err
+	if cleanup != nil {
+		cleanup()
+	}
+}
+
+// terminate sends SIGTERM to the process group and SIGKILL once the grace
+// period has passed if anything in the group is still running.
+func (p *process) terminate() {
+	pgid := p.cmd.Process.Pid
+	syscall.Kill(-pgid, syscall.SIGTERM)
+
+	grace := p.opts.GracePeriod
+	if grace == 0 {
+		grace = DefaultGracePeriod
 	}
-	args = append(args, "--")
-	return args
+	timer := time.NewTimer(grace)
+	defer timer.Stop()
+	// The output is only finished once everything holding the pipes, which
+	// includes any children, has exited.
+	for _, done := range []chan struct{}{p.exited, p.copied} {
+		select {
+		case <-done:
+		case <-timer.C:
+			syscall.Kill(-pgid, syscall.SIGKILL)
+			return
+		}
+	}
+	// Catch anything in the group which does not write output
+	syscall.Kill(-pgid, syscall.SIGKILL)
 }
 
-func ExecuteCmdNamespace(cmdName string, args []string, ns Namespaces, stream rpcapi.Exec_SendExecServer) error {
-	outPipe, pw, err := os.Pipe()
+func winsize(size *rpcapi.WindowSize) *pty.Winsize {
+	return &pty.Winsize{
+		Rows: uint16(size.Rows),
+		Cols: uint16(size.Cols),
+	}
+}
+
+// startPipes starts the command with stdout and stderr on separate pipes
+func startPipes(cmd *exec.Cmd, opts ExecOptions, stream ReplyStream) (*process, error) {
+	outPipe, outW, err := os.Pipe()
 	if err != nil {
-		return err
+		return nil, err
+	}
+	errPipe, errW, err := os.Pipe()
+	if err != nil {
+		outPipe.Close()
+		outW.Close()
+		return nil, err
+	}
+	pipes := []*os.File{outPipe, outW, errPipe, errW}
+	cmd.Stdout = outW
+	cmd.Stderr = errW
+
+	// We do not hand stdin directly to exec.Cmd as Wait() would then block
+	// until the client closed stdin, even after the process exited.
+	var inPipe, inW *os.File
+	if opts.Stdin != nil {
+		inPipe, inW, err = os.Pipe()
+		if err != nil {
+			for _, f := range pipes {
+				f.Close()
+			}
+			return nil, err
+		}
+		cmd.Stdin = inPipe
+		pipes = append(pipes, inPipe, inW)
 	}
 
-	nsenterArgs := append(ns.args(), cmdName)
-	nsenterArgs = append(nsenterArgs, args...)
-	cmd := exec.Command("nsenter", nsenterArgs...)
-	cmd.Stdout = pw
-	cmd.Stderr = pw
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Setpgid: true,
+	}
 
+	p := newProcess(cmd, opts, stream)
 	if err := cmd.Start(); err != nil {
-		return err
+		for _, f := range pipes {
+			f.Close()
+		}
+		return nil, err
+	}
+
+	// Feed the client's input to the process until the client sends EOF or
+	// the process stops reading.
+	if inW != nil {
+		inPipe.Close()
+		go func() {
+			io.Copy(inW, opts.Stdin)
+			inW.Close()
+		}()
 	}
 
-	finished := make(chan bool)
+	// When the process ends, close the pipes. This will cause the io.Copy()
+	// calls to hit EOF and return.
+	go p.reap(func() {
+		outW.Close()
+		errW.Close()
+	})
 
-	// When the process ends, close the pipe. This will cause the io.Copy() to
-	// hit EOF and return.
+	// Once both copies returned we either hit an error or both pipes returned
+	// EOF. In either case, we've done all we can do with the output.
+	var wg sync.WaitGroup
+	wg.Add(2)
 	go func() {
-		cmd.Wait()
-		pw.Close()
+		defer wg.Done()
+		copyOutput(p.output, rpcapi.ExecReply_STDOUT, outPipe)
 	}()
-
-	// If the client closes the stream mark that we are finished so we may
-	// stop the exec early if needed.
 	go func() {
-		select {
-		case <-stream.Context().Done():
-			finished <- true
-		}
+		defer wg.Done()
+		copyOutput(p.output, rpcapi.ExecReply_STDERR, errPipe)
 	}()
+	go func() {
+		wg.Wait()
+		close(p.copied)
+	}()
+	return p, nil
+}
+
+// startTTY starts the command with a new pseudo terminal as its controlling
+// terminal, stdin, stdout and stderr. The command leads a new session, and
+// so a new process group.
+func startTTY(cmd *exec.Cmd, opts ExecOptions, stream ReplyStream) (*process, error) {
+	p := newProcess(cmd, opts, stream)
+	master, err := pty.StartWithSize(cmd, winsize(opts.TTY))
+	if err != nil {
+		return nil, err
+	}
+
+	go p.reap(nil)
+
+	if opts.Stdin != nil {
+		go io.Copy(master, opts.Stdin)
+	}
 
-	// If the io.Copy() returned that means we either hit an error or outPipe
-	// return EOF. In either case, we've done all we can do, so indicate we
-	// are finished and should return.
 	go func() {
-		defer func() {
-			finished <- true
-			outPipe.Close()
-		}()
-		sw := streamWriter{
-			stream: stream,
-		}
 		for {
-			l, err := io.Copy(sw, outPipe)
-			if err != nil || l == 0 {
+			select {
+			case size := <-opts.Resize:
+				pty.Setsize(master, winsize(size))
+			case <-p.copied:
 				return
 			}
 		}
 	}()
 
-	select {
-	case <-finished:
-		// If the process is still running after we are finished
-		// we should kill it. After the call to Wait() cmd.ProcessState
-		// should be non-nil.
-		if cmd.ProcessState == nil || !cmd.ProcessState.Exited() {
-			cmd.Process.Kill()
+	// Reading the master returns EIO once the process and all of its
+	// children have closed the terminal.
+	go func() {
+		copyOutput(p.output, rpcapi.ExecReply_STDOUT, master)
+		close(p.copied)
+	}()
+	return p, nil
+}
+
+// wait sends the final status to the client once all output has been sent
+// and the process exited.
+func (p *process) wait(stream ReplyStream) error {
+	var timeout <-chan time.Time
+	if p.opts.Timeout > 0 {
+		timer := time.NewTimer(p.opts.Timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	// Children may keep running, and holding the output, after the process
+	// exited so the cgroup is watched until everything is finished
+	var breach chan string
+	if p.cgroup != nil {
+		breach = make(chan string, 1)
+		done := make(chan struct{})
+		defer close(done)
+		go p.cgroup.watch(done, breach)
+	}
+
+	ctx := stream.Context()
+	copied := p.copied
+	waited := p.waited
+	truncated := p.output.truncated
+	timedOut := false
+	reason := ""
+	var waitErr error
+	for copied != nil || waited != nil {
+		select {
+		case <-copied:
+			copied = nil
+		case waitErr = <-waited:
+			waited = nil
+		case breached := <-breach:
+			if reason == "" {
+				reason = breached
+			}
+			p.cgroup.kill()
+		case <-truncated:
+			// Everything else the process writes is dropped
+			truncated = nil
+			if reason == "" {
+				reason = ReasonOutputLimit
+			}
+			go p.terminate()
+		case <-timeout:
+			timeout = nil
+			timedOut = true
+			go p.terminate()
+		case <-ctx.Done():
+			// If the client closes the stream we are finished and should
+			// stop the process early, there is nobody left to send the
+			// output or status to.
+			p.terminate()
+			return ctx.Err()
 		}
 	}
+	// A non-zero exit is reported in the status, not as an rpc error.
+	if _, ok := waitErr.(*exec.ExitError); waitErr != nil && !ok {
+		return waitErr
+	}
+
+	// The kernel may have killed the process for breaching a limit before
+	// it was noticed
+	if reason == "" && p.cgroup != nil {
+		reason = p.cgroup.breached()
+	}
 
-	return nil
+	status := execStatus(p.cmd.ProcessState, time.Since(p.start))
+	status.TimedOut = timedOut
+	status.Reason = reason
+	reply := &rpcapi.ExecReply{
+		Status: status,
+	}
+	return stream.Send(reply)
+}
+
+// ExecuteCmdNamespace runs the command in the given namespaces and sends the
+// output and final status to the client. The server re-executes itself as
+// the nsenter helper, which enters the namespaces and then executes the
+// command. If that fails a FailedPrecondition error saying which step failed
+// is returned instead of a status. The helper sets the privileges, if any,
+// just before executing the command. With limits the helper joins a cgroup
+// created for the command right before that, so only the command and what it
+// starts count against them. The cgroup is removed along with anything left
+// in it once the command exited.
+func ExecuteCmdNamespace(cmdName string, args []string, ns Namespaces, opts ExecOptions, stream ReplyStream) error {
+	errR, errW, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer errR.Close()
+	cmd := exec.Command("/proc/self/exe")
+	cmd.Args = append([]string{"nsenter", cmdName}, args...)
+	cmd.Env = append(os.Environ(), nsenter.EnvName+"="+ns.env())
+	cmd.ExtraFiles = []*os.File{errW}
+	if opts.Privileges != nil {
+		privileges, err := opts.Privileges.Encode()
+		if err != nil {
+			errW.Close()
+			return err
+		}
+		cmd.Env = append(cmd.Env, nsenter.PrivilegesEnvName+"="+privileges)
+	}
+
+	var cg *cgroup
+	if opts.Limits != nil {
+		if cg, err = newCgroup(opts.Limits); err != nil {
+			errW.Close()
+			return grpc.Errorf(codes.FailedPrecondition, "Unable to create cgroup: %v", err)
+		}
+		defer cg.remove()
+		cmd.Env = append(cmd.Env, nsenter.CgroupEnvName+"="+strings.Join(cg.dirs, ":"))
+	}
+
+	var p *process
+	if opts.TTY != nil {
+		p, err = startTTY(cmd, opts, stream)
+	} else {
+		p, err = startPipes(cmd, opts, stream)
+	}
+	errW.Close()
+	if err != nil {
+		return err
+	}
+	if err := nsenter.ReadError(errR); err != nil {
+		<-p.exited
+		<-p.copied
+		return grpc.Errorf(codes.FailedPrecondition, "%v", err)
+	}
+	p.cgroup = cg
+	return p.wait(stream)
 }
 
-func ExecuteCmdSelfNS(cmdName string, args []string, stream rpcapi.Exec_SendExecServer) error {
-	return ExecuteCmdNamespace(cmdName, args, selfNamespace, stream)
+func ExecuteCmdSelfNS(cmdName string, args []string, opts ExecOptions, stream ReplyStream) error {
+	return ExecuteCmdNamespace(cmdName, args, selfNamespace, opts, stream)
 }
 
-func ExecuteCmdInitNS(cmdName string, args []string, stream rpcapi.Exec_SendExecServer) error {
-	return ExecuteCmdNamespace(cmdName, args, initNamespace, stream)
+func ExecuteCmdInitNS(cmdName string, args []string, opts ExecOptions, stream ReplyStream) error {
+	return ExecuteCmdNamespace(cmdName, args, initNamespace, opts, stream)
 }
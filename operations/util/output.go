@@ -0,0 +1,111 @@
+package util
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	rpcapi "github.com/eparis/admin-rpc/api"
+)
+
+const (
+	// outputChunkSize is the most output sent in one message
+	outputChunkSize = 32 * 1024
+	// outputFlushInterval is how long output is held back so it can be
+	// sent along with the output which follows it
+	outputFlushInterval = 20 * time.Millisecond
+)
+
+// output sends the output of a process to the client. stdout and stderr are
+// copied concurrently and grpc streams are not safe for concurrent Send() so
+// both share one output.
+type output struct {
+	stream ReplyStream
+	lock   sync.Mutex
+	// max is the most output sent, 0 means no limit
+	max  int64
+	sent int64
+	// limiter, if set, limits the bytes sent per second
+	limiter *rate.Limiter
+	// truncated is closed once output was dropped for going over max
+	truncated chan struct{}
+	cut       bool
+}
+
+func newOutput(stream ReplyStream, opts ExecOptions) *output {
+	o := &output{
+		stream:    stream,
+		max:       opts.MaxOutputBytes,
+		truncated: make(chan struct{}),
+	}
+	if opts.OutputBytesPerSecond > 0 {
+		o.limiter = rate.NewLimiter(rate.Limit(opts.OutputBytesPerSecond), outputChunkSize)
+	}
+	return o
+}
+
+// send sends p, of at most outputChunkSize, to the client tagged with the
+// output it came from. Output over max is dropped, the first time along with
+// a message marking where the output was truncated.
+func (o *output) send(source rpcapi.ExecReply_Stream, p []byte) error {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	truncate := false
+	if o.max > 0 && int64(len(p)) > o.max-o.sent {
+		p = p[:o.max-o.sent]
+		truncate = !o.cut
+		o.cut = true
+	}
+	if len(p) > 0 {
+		if o.limiter != nil {
+			if err := o.limiter.WaitN(o.stream.Context(), len(p)); err != nil {
+				return err
+			}
+		}
+		cr := &rpcapi.ExecReply{
+			Output: p,
+			Stream: source,
+		}
+		if err := o.stream.Send(cr); err != nil {
+			return err
+		}
+		o.sent += int64(len(p))
+	}
+	if truncate {
+		close(o.truncated)
+		return o.stream.Send(&rpcapi.ExecReply{Truncated: true})
+	}
+	return nil
+}
+
+// copyOutput sends everything from the pipe to the client until the pipe
+// returns EOF or the client goes away. Reads which follow each other within
+// outputFlushInterval are sent together, if the pipe supports deadlines.
+func copyOutput(o *output, source rpcapi.ExecReply_Stream, pipe *os.File) {
+	defer pipe.Close()
+	buf := make([]byte, outputChunkSize)
+	for {
+		n, err := pipe.Read(buf)
+		if err == nil && pipe.SetReadDeadline(time.Now().Add(outputFlushInterval)) == nil {
+			for n < len(buf) && err == nil {
+				var more int
+				more, err = pipe.Read(buf[n:])
+				n += more
+			}
+			pipe.SetReadDeadline(time.Time{})
+			if os.IsTimeout(err) {
+				err = nil
+			}
+		}
+		if n > 0 {
+			if err := o.send(source, buf[:n]); err != nil {
+				return
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
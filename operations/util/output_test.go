@@ -0,0 +1,187 @@
+package util
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	rpcapi "github.com/eparis/admin-rpc/api"
+)
+
+// fakeStream records what is sent to the client
+type fakeStream struct {
+	lock    sync.Mutex
+	replies []*rpcapi.ExecReply
+}
+
+func (s *fakeStream) Send(reply *rpcapi.ExecReply) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.replies = append(s.replies, reply)
+	return nil
+}
+
+func (s *fakeStream) Context() context.Context {
+	return context.Background()
+}
+
+// chunks describes what was sent, the size of each output and -1 for the
+// truncation marker
+func (s *fakeStream) chunks() []int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	chunks := []int{}
+	for _, reply := range s.replies {
+		switch {
+		case reply.Truncated:
+			chunks = append(chunks, -1)
+		case reply.Status == nil:
+			chunks = append(chunks, len(reply.Output))
+		}
+	}
+	return chunks
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestOutputSend(t *testing.T) {
+	tests := []struct {
+		name   string
+		max    int64
+		writes []int
+		// chunks are the sizes sent, -1 is the truncation marker
+		chunks []int
+	}{
+		{"no limit", 0, []int{10, 20, 30}, []int{10, 20, 30}},
+		{"under the limit", 100, []int{10, 20, 30}, []int{10, 20, 30}},
+		{"exactly the limit", 60, []int{10, 20, 30}, []int{10, 20, 30}},
+		{"cut in a write", 50, []int{10, 20, 30, 40}, []int{10, 20, 20, -1}},
+		{"cut between writes", 30, []int{10, 20, 30, 40}, []int{10, 20, -1}},
+		{"first write cut", 5, []int{10, 20}, []int{5, -1}},
+	}
+	for _, test := range tests {
+		stream := &fakeStream{}
+		o := newOutput(stream, ExecOptions{MaxOutputBytes: test.max})
+		for _, n := range test.writes {
+			if err := o.send(rpcapi.ExecReply_STDOUT, make([]byte, n)); err != nil {
+				t.Fatalf("%s: unexpected error: %v", test.name, err)
+			}
+		}
+		if chunks := stream.chunks(); !equalInts(chunks, test.chunks) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.chunks, chunks)
+		}
+		cut := false
+		select {
+		case <-o.truncated:
+			cut = true
+		default:
+		}
+		if truncated := test.chunks[len(test.chunks)-1] == -1; cut != truncated {
+			t.Errorf("%s: expected truncated %t, got %t", test.name, truncated, cut)
+		}
+	}
+}
+
+func TestOutputRateLimit(t *testing.T) {
+	stream := &fakeStream{}
+	o := newOutput(stream, ExecOptions{OutputBytesPerSecond: 1024 * 1024})
+	start := time.Now()
+	// The first chunk is the burst, the other three take 3/32 of a second
+	for i := 0; i < 4; i++ {
+		if err := o.send(rpcapi.ExecReply_STDOUT, make([]byte, outputChunkSize)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 80*time.Millisecond {
+		t.Errorf("expected sending to take at least 80ms, took %v", elapsed)
+	}
+	if chunks := stream.chunks(); !equalInts(chunks, []int{outputChunkSize, outputChunkSize, outputChunkSize, outputChunkSize}) {
+		t.Errorf("unexpected chunks: %v", chunks)
+	}
+}
+
+func TestCopyOutput(t *testing.T) {
+	type write struct {
+		// after is how long to wait before writing
+		after time.Duration
+		size  int
+	}
+	tests := []struct {
+		name   string
+		writes []write
+		chunks []int
+	}{
+		{"small writes coalesced", []write{{0, 10}, {time.Millisecond, 10}, {time.Millisecond, 10}}, []int{30}},
+		{"writes apart", []write{{0, 10}, {200 * time.Millisecond, 10}}, []int{10, 10}},
+		{"large write split", []write{{0, outputChunkSize + 100}}, []int{outputChunkSize, 100}},
+	}
+	for _, test := range tests {
+		r, w, err := os.Pipe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		stream := &fakeStream{}
+		o := newOutput(stream, ExecOptions{})
+		done := make(chan struct{})
+		go func() {
+			copyOutput(o, rpcapi.ExecReply_STDOUT, r)
+			close(done)
+		}()
+		for _, wr := range test.writes {
+			time.Sleep(wr.after)
+			if _, err := w.Write(make([]byte, wr.size)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		w.Close()
+		<-done
+		if chunks := stream.chunks(); !equalInts(chunks, test.chunks) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.chunks, chunks)
+		}
+	}
+}
+
+func TestOutputLimitReason(t *testing.T) {
+	stream := &fakeStream{}
+	opts := ExecOptions{MaxOutputBytes: 1000}
+	cmd := exec.Command("sh", "-c", "head -c 100000 /dev/zero; sleep 10")
+	p, err := startPipes(cmd, opts, stream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.wait(stream); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var output bytes.Buffer
+	truncated := false
+	var status *rpcapi.ExecStatus
+	for _, reply := range stream.replies {
+		output.Write(reply.Output)
+		truncated = truncated || reply.Truncated
+		if reply.Status != nil {
+			status = reply.Status
+		}
+	}
+	if output.Len() != 1000 || !truncated {
+		t.Errorf("expected 1000 bytes and the truncation marker, got %d and %t", output.Len(), truncated)
+	}
+	if status == nil || status.Reason != ReasonOutputLimit || status.Signal != "SIGTERM" {
+		t.Errorf("expected the command to be terminated for %s, got %+v", ReasonOutputLimit, status)
+	}
+}
@@ -12,6 +12,7 @@ type authContext string
 var (
 	tokenAuthInfo = authContext("tokenInfo")
 	clientSetInfo = authContext("clientSet")
+	accessCache   = authContext("accessCache")
 )
 
 func GetClientset(ctx context.Context) *kubernetes.Clientset {
@@ -42,3 +43,13 @@ func PutToken(ctx context.Context, tokenInfo *authnv1.TokenReview) context.Conte
 	// save the TokenReview api object to the context for later use
 	return context.WithValue(ctx, tokenAuthInfo, tokenInfo)
 }
+
+// GetAccessCache returns the cache of SubjectAccessReviews, nil if there is none
+func GetAccessCache(ctx context.Context) *Cache {
+	cache, _ := ctx.Value(accessCache).(*Cache)
+	return cache
+}
+
+func PutAccessCache(ctx context.Context, cache *Cache) context.Context {
+	return context.WithValue(ctx, accessCache, cache)
+}
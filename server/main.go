@@ -18,6 +18,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -26,12 +27,33 @@ import (
 type serverConfig struct {
 	cfgDir      string
 	serviceName string
+	// nodeName is the node the server runs on. The daemonset sets NODE_NAME
+	// from spec.nodeName.
+	nodeName string
+
+	// How many TokenReviews and SubjectAccessReviews are cached and for how
+	// long. Negative answers, unauthenticated or denied, are kept for less
+	// time so new grants take effect quickly.
+	tokenCacheSize         int
+	tokenCacheTTL          time.Duration
+	tokenCacheNegativeTTL  time.Duration
+	accessCacheSize        int
+	accessCacheTTL         time.Duration
+	accessCacheNegativeTTL time.Duration
 }
 
 var (
 	srvCfg = serverConfig{
 		cfgDir:      "/etc/admin-rpc",
 		serviceName: "rpc.eparis.svc",
+		nodeName:    os.Getenv("NODE_NAME"),
+
+		tokenCacheSize:         1024,
+		tokenCacheTTL:          2 * time.Minute,
+		tokenCacheNegativeTTL:  10 * time.Second,
+		accessCacheSize:        4096,
+		accessCacheTTL:         time.Minute,
+		accessCacheNegativeTTL: 10 * time.Second,
 	}
 
 	rootCmd = &cobra.Command{
@@ -45,6 +67,13 @@ func init() {
 	cobra.OnInitialize(initConfig)
 
 	rootCmd.PersistentFlags().StringVar(&srvCfg.cfgDir, "config-dir", srvCfg.cfgDir, "config directory")
+	rootCmd.PersistentFlags().StringVar(&srvCfg.nodeName, "node-name", srvCfg.nodeName, "node the server runs on, defaults to $NODE_NAME")
+	rootCmd.PersistentFlags().IntVar(&srvCfg.tokenCacheSize, "token-cache-size", srvCfg.tokenCacheSize, "number of TokenReviews to cache")
+	rootCmd.PersistentFlags().DurationVar(&srvCfg.tokenCacheTTL, "token-cache-ttl", srvCfg.tokenCacheTTL, "how long to cache authenticated tokens, 0 to not cache them")
+	rootCmd.PersistentFlags().DurationVar(&srvCfg.tokenCacheNegativeTTL, "token-cache-negative-ttl", srvCfg.tokenCacheNegativeTTL, "how long to cache unauthenticated tokens, 0 to not cache them")
+	rootCmd.PersistentFlags().IntVar(&srvCfg.accessCacheSize, "access-cache-size", srvCfg.accessCacheSize, "number of SubjectAccessReviews to cache")
+	rootCmd.PersistentFlags().DurationVar(&srvCfg.accessCacheTTL, "access-cache-ttl", srvCfg.accessCacheTTL, "how long to cache allowed SubjectAccessReviews, 0 to not cache them")
+	rootCmd.PersistentFlags().DurationVar(&srvCfg.accessCacheNegativeTTL, "access-cache-negative-ttl", srvCfg.accessCacheNegativeTTL, "how long to cache denied SubjectAccessReviews, 0 to not cache them")
 }
 
 func initConfig() {
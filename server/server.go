@@ -2,6 +2,7 @@
 package main
 
 import (
+	"crypto/sha256"
 	"crypto/tls"
 	"fmt"
 	"log"
@@ -17,6 +18,7 @@ import (
 	"github.com/spf13/cobra"
 	"golang.org/x/net/context"
 	authnv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
@@ -42,12 +44,27 @@ import (
 var (
 	_          = pretty.Print
 	kubeConfig *rest.Config
+	clientset  *kubernetes.Clientset
 	bindAddr   = ":12021"
 	localAddr  = "127.0.0.1:12021"
+
+	// tokenCache caches TokenReviews by the hash of the token, accessCache
+	// SubjectAccessReviews by the requestor and what they asked to do
+	tokenCache  *util.Cache
+	accessCache *util.Cache
 )
 
-// validateToken will ask the Kubernetes API Server to do a TokenReview
+// validateToken will ask the Kubernetes API Server to do a TokenReview. The
+// answer is cached, unless the API Server could not be asked.
 func validateToken(clientset *kubernetes.Clientset, token string) (*authnv1.TokenReview, error) {
+	key := fmt.Sprintf("%x", sha256.Sum256([]byte(token)))
+	if cached, ok := tokenCache.Get(key); ok {
+		if err, ok := cached.(error); ok {
+			return nil, err
+		}
+		return cached.(*authnv1.TokenReview), nil
+	}
+
 	tr := &authnv1.TokenReview{
 		Spec: authnv1.TokenReviewSpec{
 			Token: token,
@@ -58,20 +75,23 @@ func validateToken(clientset *kubernetes.Clientset, token string) (*authnv1.Toke
 		return nil, err
 	}
 	if !tr.Status.Authenticated {
+		err := fmt.Errorf("Response from TokenReview was unauthenticated")
 		if tr.Status.Error != "" {
-			return nil, fmt.Errorf("%s", tr.Status.Error)
+			err = fmt.Errorf("%s", tr.Status.Error)
 		}
-		return nil, fmt.Errorf("Response from RokenReview was unauthenticated")
+		tokenCache.Add(key, err, false)
+		return nil, err
 	}
 
+	tokenCache.Add(key, tr, true)
 	return tr, nil
 }
 
 // attachAuthnData will attach the kubernetes clientset and TokenReview to the context.Context
 func attachAuthnData(ctx context.Context) (context.Context, error) {
-	clientset, err := kubernetes.NewForConfig(kubeConfig)
-	if err != nil {
-		return nil, err
+	// adds node.name to the audit messages, even of requests which are refused
+	if srvCfg.nodeName != "" {
+		grpc_ctxtags.Extract(ctx).Set("node.name", srvCfg.nodeName)
 	}
 
 	token, err := grpc_auth.AuthFromMD(ctx, "bearer")
@@ -88,12 +108,31 @@ func attachAuthnData(ctx context.Context) (context.Context, error) {
 	// store the token for later
 	ctx = util.PutToken(ctx, tokenInfo)
 	ctx = util.PutClientset(ctx, clientset)
+	ctx = util.PutAccessCache(ctx, accessCache)
 	return ctx, nil
 }
 
+// nodeLabels returns the labels of the node the server is running on. The
+// labels are nil if the node is not known.
+func nodeLabels(nodeName string) map[string]string {
+	if nodeName == "" {
+		log.Printf("The node is not known, set --node-name or NODE_NAME. Commands which need node labels will not be loaded")
+		return nil
+	}
+	node, err := clientset.CoreV1().Nodes().Get(nodeName, metav1.GetOptions{})
+	if err != nil {
+		log.Printf("Unable to get node %s: %v", nodeName, err)
+		return nil
+	}
+	if node.Labels == nil {
+		return map[string]string{}
+	}
+	return node.Labels
+}
+
 // Register all of the operations which are defined with the server
 func registerAllOperations(grpcServer *grpc.Server) error {
-	sndCmd, err := command.NewExec(srvCfg.cfgDir)
+	sndCmd, err := command.NewExec(srvCfg.cfgDir, srvCfg.nodeName, nodeLabels(srvCfg.nodeName))
 	if err != nil {
 		return err
 	}
@@ -127,6 +166,13 @@ func mainFunc(cmd *cobra.Command, args []string) error {
 		}
 	}
 
+	clientset, err = kubernetes.NewForConfig(kubeConfig)
+	if err != nil {
+		return err
+	}
+	tokenCache = util.NewCache("token", srvCfg.tokenCacheSize, srvCfg.tokenCacheTTL, srvCfg.tokenCacheNegativeTTL)
+	accessCache = util.NewCache("access", srvCfg.accessCacheSize, srvCfg.accessCacheTTL, srvCfg.accessCacheNegativeTTL)
+
 	err = initCerts()
 	if err != nil {
 		return err
@@ -182,7 +228,8 @@ func mainFunc(cmd *cobra.Command, args []string) error {
 	dopts := []grpc.DialOption{
 		grpc.WithTransportCredentials(dcreds),
 	}
-	gwmux := runtime.NewServeMux()
+	// EmitDefaults so the output stream is shown even when it is STDOUT
+	gwmux := runtime.NewServeMux(runtime.WithMarshalerOption(runtime.MIMEWildcard, &runtime.JSONPb{OrigName: true, EmitDefaults: true}))
 	err = rpcapi.RegisterExecHandlerFromEndpoint(ctx, gwmux, localAddr, dopts)
 	if err != nil {
 		log.Fatalf("RegisterExecHandlerFromEndpoint: %v\n", err)